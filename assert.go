@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab.com/thedahv/pnguin/png"
+)
+
+// dimensions is an image size given on the command line as WxH
+type dimensions struct {
+	width, height uint32
+}
+
+// parseDimensions reads a size such as "1920x1080".
+func parseDimensions(s string) (dimensions, error) {
+	var d dimensions
+	w, h, ok := strings.Cut(s, "x")
+	if !ok {
+		return d, fmt.Errorf("%q is not of the form WxH", s)
+	}
+
+	width, err := strconv.Atoi(w)
+	if err != nil || width < 1 {
+		return d, fmt.Errorf("invalid width %q", w)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height < 1 {
+		return d, fmt.Errorf("invalid height %q", h)
+	}
+
+	return dimensions{uint32(width), uint32(height)}, nil
+}
+
+// assertDimensions checks that the image is exactly the expected size.
+func assertDimensions(p *png.Parser, want dimensions) error {
+	hdr, err := p.Header()
+	if err != nil {
+		return err
+	}
+	if hdr.Width != want.width || hdr.Height != want.height {
+		return fmt.Errorf("%s is %dx%d, expected %dx%d", p.Path, hdr.Width,
+			hdr.Height, want.width, want.height)
+	}
+
+	return nil
+}
+
+// exitExifPresent is the exit status when -assert-no-exif finds EXIF data,
+// kept apart from other failures so privacy checks can be told apart
+const exitExifPresent = 3
+
+// assertNoExif checks that the image holds no eXIf chunks.
+func assertNoExif(p *png.Parser) error {
+	if hasChunk(p, "eXIf") {
+		return fmt.Errorf("%s contains EXIF data in an eXIf chunk", p.Path)
+	}
+
+	return nil
+}
+
+// assertMetadataRatio checks that the metadata chunks make up at most max of
+// the image's file size, given as a fraction.
+func assertMetadataRatio(p *png.Parser, max float64) error {
+	size := fileSize(p)
+	if size == 0 {
+		return nil
+	}
+
+	ratio := float64(p.MetadataSize()) / float64(size)
+	if ratio > max {
+		return fmt.Errorf("%s is %.1f%% metadata (%s of %s), over the %.1f%% "+
+			"limit", p.Path, ratio*100, formatSize(p.MetadataSize()),
+			formatSize(size), max*100)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// BatchResult records what happened to one image of a batch.
+type BatchResult struct {
+	Path string
+	// ChunksFound is the number of chunks in the image as parsed
+	ChunksFound int
+	// ChunksStripped is the number of chunks left out of the cleaned copy
+	ChunksStripped int
+	// InputSize is the size of the image file, or 0 if it is unknown
+	InputSize int64
+	// OutputSize is the size of the cleaned copy, or 0 if none was written
+	OutputSize int64
+	Err        error
+}
+
+// Batch collects the results of processing several images.
+type Batch []BatchResult
+
+// SuccessCount gives the number of images processed without error.
+func (b Batch) SuccessCount() int {
+	var n int
+	for _, r := range b {
+		if r.Err == nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ErrorCount gives the number of images that could not be processed.
+func (b Batch) ErrorCount() int {
+	return len(b) - b.SuccessCount()
+}
+
+// TotalSaved gives the number of bytes saved across the images that were
+// cleaned.
+func (b Batch) TotalSaved() int64 {
+	var saved int64
+	for _, r := range b {
+		if r.Err == nil && r.InputSize > 0 && r.OutputSize > 0 {
+			saved += r.InputSize - r.OutputSize
+		}
+	}
+
+	return saved
+}
+
+// Format writes the results to w. The "text" format gives a totals line
+// followed by a line per image; the "tsv" format gives a header row followed by
+// a tab-separated row per image for use in other tools.
+func (b Batch) Format(w io.Writer, format string) error {
+	switch format {
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "%d files: %d succeeded, %d failed, saved %s\n",
+			len(b), b.SuccessCount(), b.ErrorCount(), formatSize(b.TotalSaved()))
+		for _, r := range b {
+			if r.Err != nil {
+				fmt.Fprintf(tw, "  %s\terror: %v\n", r.Path, r.Err)
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%d chunks\t%d stripped\t%s -> %s\n", r.Path,
+				r.ChunksFound, r.ChunksStripped, formatSize(r.InputSize),
+				formatSize(r.OutputSize))
+		}
+		return tw.Flush()
+	case "tsv":
+		fmt.Fprintln(w, "path\tchunks\tstripped\tinput\toutput\terror")
+		for _, r := range b {
+			var msg string
+			if r.Err != nil {
+				msg = r.Err.Error()
+			}
+			_, err := fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", r.Path,
+				r.ChunksFound, r.ChunksStripped, r.InputSize, r.OutputSize, msg)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
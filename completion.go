@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const completionHelp = `usage: pnguin completion bash|zsh|fish
+
+Prints a script that completes pnguin flags, commands, and image paths.
+
+To install it:
+
+  bash  add to ~/.bashrc:
+          source <(pnguin completion bash)
+  zsh   write it to a directory on $fpath, e.g.:
+          pnguin completion zsh > "${fpath[1]}/_pnguin"
+  fish  write it to your completions directory:
+          pnguin completion fish > ~/.config/fish/completions/pnguin.fish
+`
+
+// completionFlag describes a command line flag for the completion templates
+type completionFlag struct {
+	Name    string
+	Arg     string
+	IsBool  bool
+	IsPath  bool
+	IsDir   bool
+	Summary string
+}
+
+// runCompletion implements the completion command.
+func runCompletion(args []string) int {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, completionHelp)
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	tmpl, ok := completionScripts[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unsupported shell %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var cmds []completionFlag
+	for _, name := range names {
+		cmds = append(cmds, completionFlag{Name: name, Summary: commands[name].summary})
+	}
+
+	data := struct {
+		Flags    []completionFlag
+		Commands []completionFlag
+	}{completionFlags(flag.CommandLine), cmds}
+
+	if err := tmpl.Execute(os.Stdout, data); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write completion script: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// completionFlags describes every flag defined in fs.
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		arg, usage := flag.UnquoteUsage(f)
+		b, isBool := f.Value.(interface{ IsBoolFlag() bool })
+
+		flags = append(flags, completionFlag{
+			Name:    f.Name,
+			Arg:     arg,
+			IsBool:  isBool && b.IsBoolFlag(),
+			IsPath:  arg == "path",
+			IsDir:   arg == "dir",
+			Summary: strings.Join(strings.Fields(usage), " "),
+		})
+	})
+
+	return flags
+}
+
+// quote escapes s for use inside single quotes in a shell script.
+func quote(s string) string {
+	return strings.ReplaceAll(s, "'", `'\''`)
+}
+
+// zshDesc escapes s for use as an option description in zsh's _arguments.
+func zshDesc(s string) string {
+	r := strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`)
+	return quote(r.Replace(s))
+}
+
+var completionFuncs = template.FuncMap{
+	"quote":   quote,
+	"zshDesc": zshDesc,
+}
+
+var completionScripts = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Funcs(completionFuncs).Parse(
+		`# bash completion for pnguin
+_pnguin() {
+    local cur prev
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+{{- range .Flags}}{{if not .IsBool}}
+    -{{.Name}}|--{{.Name}})
+        {{if .IsDir}}COMPREPLY=( $(compgen -d -- "$cur") ){{else if .IsPath}}COMPREPLY=( $(compgen -f -- "$cur") ){{else}}COMPREPLY=(){{end}}
+        return ;;
+{{- end}}{{end}}
+    esac
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=( $(compgen -W "{{range .Flags}}-{{.Name}} {{end}}" -- "$cur") )
+        return
+    fi
+
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=( $(compgen -W "{{range .Commands}}{{.Name}} {{end}}" -- "$cur") )
+    fi
+    COMPREPLY+=( $(compgen -f -X '!*.[pP][nN][gG]' -- "$cur") )
+}
+complete -o plusdirs -F _pnguin pnguin
+`)),
+
+	"zsh": template.Must(template.New("zsh").Funcs(completionFuncs).Parse(
+		`#compdef pnguin
+# zsh completion for pnguin
+_pnguin() {
+    local -a commands
+    commands=(
+{{- range .Commands}}
+        '{{.Name}}:{{quote .Summary}}'
+{{- end}}
+    )
+
+    _arguments \
+{{- range .Flags}}
+        '-{{.Name}}[{{zshDesc .Summary}}]{{if not .IsBool}}:{{.Arg}}:{{if .IsDir}}_files -/{{else if .IsPath}}_files{{else}} {{end}}{{end}}' \
+{{- end}}
+        '1: :{_describe command commands; _files -g "*.(png|PNG)"}' \
+        '*:image:_files -g "*.(png|PNG)"'
+}
+_pnguin "$@"
+`)),
+
+	"fish": template.Must(template.New("fish").Funcs(completionFuncs).Parse(
+		`# fish completion for pnguin
+{{- range .Commands}}
+complete -c pnguin -n '__fish_use_subcommand' -f -a '{{.Name}}' -d '{{quote .Summary}}'
+{{- end}}
+{{- range .Flags}}
+complete -c pnguin -o '{{.Name}}' -d '{{quote .Summary}}'{{if not .IsBool}} -r{{if not (or .IsPath .IsDir)}} -f{{end}}{{end}}
+{{- end}}
+complete -c pnguin -k -a '(__fish_complete_suffix .png)'
+`)),
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// defaultConfig is read from the current directory when -config is not given
+const defaultConfig = ".pnguin.json"
+
+// loadConfig sets the flags of fs from the JSON object in the file at path,
+// whose keys are flag names. Flags already set on the command line keep their
+// values. A missing file is only an error when required is set.
+func loadConfig(fs *flag.FlagSet, path string, required bool) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var values map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("malformed config file %s: %v", path, err)
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for name, v := range values {
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown option %q in config file %s", name, path)
+		}
+		if set[name] {
+			continue
+		}
+
+		var list []interface{}
+		if l, ok := v.([]interface{}); ok {
+			list = l
+		} else {
+			list = []interface{}{v}
+		}
+
+		for _, item := range list {
+			var s string
+			switch val := item.(type) {
+			case string:
+				s = val
+			case bool:
+				s = strconv.FormatBool(val)
+			case json.Number:
+				s = val.String()
+			default:
+				return fmt.Errorf("unsupported value for %q in config file %s",
+					name, path)
+			}
+
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("invalid value for %q in config file %s: %v",
+					name, path, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"gitlab.com/thedahv/pnguin/png"
+)
+
+// runInfo implements the info command, printing the dimensions, format, and
+// resolution of each image.
+func runInfo(args []string) int {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pnguin info imgpath ...")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		p, err := parseFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		if err := printInfo(os.Stdout, p); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to describe %s: %v\n", path, err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// printInfo writes a summary of a parsed image.
+func printInfo(out io.Writer, p *png.Parser) error {
+	hdr, err := p.Header()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\n", p.Path)
+	fmt.Fprintf(w, "  Dimensions\t%dx%d\n", hdr.Width, hdr.Height)
+	fmt.Fprintf(w, "  Bit Depth\t%d\n", hdr.BitDepth)
+	fmt.Fprintf(w, "  Color Type\t%d\n", hdr.ColorType)
+	fmt.Fprintf(w, "  Interlace Method\t%d\n", hdr.InterlaceMethod)
+
+	resolution := "unspecified"
+	if chunks := p.GetChunksByType(png.ChunkTypePxSize); len(chunks) > 0 {
+		resolution = describePhys(chunks[0].Data)
+	}
+	fmt.Fprintf(w, "  Resolution\t%s\n", resolution)
+	fmt.Fprintf(w, "  Color Space\t%s\n", p.ColorSpaceSummary())
+
+	return w.Flush()
+}
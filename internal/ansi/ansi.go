@@ -0,0 +1,50 @@
+// Package ansi formats text with ANSI terminal escape codes.
+package ansi
+
+import "os"
+
+// Escape codes for the supported styles
+const (
+	Reset  = "\x1b[0m"
+	Bold   = "\x1b[1m"
+	Red    = "\x1b[31m"
+	Green  = "\x1b[32m"
+	Yellow = "\x1b[33m"
+
+	// ClearLine erases from the cursor to the end of the line
+	ClearLine = "\x1b[K"
+)
+
+// Wrap surrounds s with the given style and a reset. An empty style leaves s
+// unchanged.
+func Wrap(style, s string) string {
+	if style == "" {
+		return s
+	}
+
+	return style + s + Reset
+}
+
+// Supported reports whether output to f should be styled: f must be a
+// terminal, TERM must name a terminal other than "dumb", and NO_COLOR must not
+// be set.
+func Supported(f *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if term := os.Getenv("TERM"); term == "" || term == "dumb" {
+		return false
+	}
+
+	return IsTerminal(f)
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gitlab.com/thedahv/pnguin/png"
+)
+
+// runListTypes implements the list-types command. It prints a table of the
+// known chunk types whose layout is kept stable for scripts.
+func runListTypes(args []string) int {
+	fs := flag.NewFlagSet("list-types", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pnguin list-types")
+		fmt.Fprintln(os.Stderr,
+			"Prints the FourCC, constant name, category, and description of each\n"+
+				"chunk type pnguin knows about.")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FOURCC\tCONSTANT\tCATEGORY\tDESCRIPTION")
+	for _, ct := range png.ChunkTypes() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ct.Abbreviation(),
+			strings.TrimPrefix(fmt.Sprintf("%#v", ct), "png."),
+			strings.ToLower(ct.Category()), description(ct))
+	}
+
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to list types: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// description gives the description of a chunk type, which follows its four
+// character code in parentheses.
+func description(ct fmt.Stringer) string {
+	s := ct.String()
+	if i := strings.Index(s, "("); i >= 0 {
+		return strings.TrimSuffix(s[i+1:], ")")
+	}
+
+	return s
+}
@@ -1,35 +1,350 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
+	"gitlab.com/thedahv/pnguin/internal/ansi"
 	"gitlab.com/thedahv/pnguin/png"
 )
 
+// options holds the operations requested on the command line
+type options struct {
+	verbose      bool
+	cleanFile    bool
+	reproducible bool
+	normalize    bool
+	stripText    bool
+	keepKeywords []string
+	injected     *png.Chunk
+	metaSource   *png.Parser
+	output       string
+}
+
+// stringList collects the values of a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// command is a subcommand run in place of the default image processing
+type command struct {
+	run     func(args []string) int
+	summary string
+}
+
+// commands are looked up by the first argument on the command line
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"completion":  {runCompletion, "Print a shell completion script"},
+		"export-meta": {runExportMeta, "Write the metadata of an image as JSON"},
+		"import-meta": {runImportMeta, "Apply JSON metadata to a copy of an image"},
+		"info":        {runInfo, "Print a summary of each image"},
+		"list-types":  {runListTypes, "List the chunk types pnguin knows about"},
+		"verify":      {runVerify, "Check the integrity of each image"},
+	}
+}
+
 func main() {
 	var parsers []*png.Parser
 
 	showTags := flag.Bool("tags", false, "Print non-data tags")
+	summary := flag.Bool("summary", false,
+		"Print a tab-separated line per image with its path, dimensions, bit\n"+
+			"depth, color type, chunk and text chunk counts, whether it has an ICC\n"+
+			"profile and EXIF data, its DPI, and its size in bytes")
 	cleanFile := flag.Bool("clean", false,
 		"Write images stripped of text tags")
+	verbose := flag.Bool("verbose", false,
+		"Print chunk offsets and sizes along with tags")
+	base64Data := flag.Bool("base64-data", false,
+		"Print binary chunk data as base64 along with tags")
+	hexdumpChunk := flag.String("hexdump-chunk", "",
+		"Print a hex dump of the first chunk of the given `type` (e.g. tEXt)")
+	extractChunk := flag.String("extract-chunk", "",
+		"Write the data of the first chunk of the given `type` to the file named\n"+
+			"by the first argument, e.g. -extract-chunk eXIf meta.exif photo.png")
+	extractICC := flag.String("extract-icc", "",
+		"Write the decompressed ICC profile of the image to `file`")
+	injectChunk := flag.String("inject-chunk", "",
+		"Insert an ancillary chunk of the given `type` before IEND, reading its\n"+
+			"data from the file named by the first argument. Requires -output")
+	copyMetaFrom := flag.String("copy-meta-from", "",
+		"Replace the ancillary chunks of the image with those of the PNG at\n"+
+			"`path`, which must match its dimensions, bit depth, and color type.\n"+
+			"Requires -output")
+	reproducible := flag.Bool("reproducible", false,
+		"Drop tIME and private chunks and sort chunks into spec order before\n"+
+			"writing, so identical images produce identical files")
+	normalize := flag.Bool("normalize", false,
+		"Drop tIME, dSIG, private chunks, and text chunks other than Author,\n"+
+			"Copyright, Description, and Comment, then sort chunks into spec order")
+	stripText := flag.Bool("strip-text", false,
+		"Drop tEXt, zTXt, and iTXt chunks before writing")
+	var keepKeywords stringList
+	flag.Var(&keepKeywords, "keep-keyword",
+		"Keep text chunks with the given `keyword` when using -strip-text. May be\n"+
+			"repeated")
+	output := flag.String("output", "",
+		"Write the modified image to `path`")
+	watchDir := flag.String("watch", "",
+		"Watch `dir` and clean PNG files as they are created or modified.\n"+
+			"Requires -clean")
+	maxSize := flag.String("max-size", "",
+		"Skip files larger than `size`, given in bytes or with a B, KB, MB, or GB\n"+
+			"suffix (multiples of 1024)")
+	strict := flag.Bool("strict", false,
+		"Stop with exit status 2 at the first file that is skipped, is not a\n"+
+			"PNG, or fails to parse")
+	ignoreErrors := flag.Bool("ignore-errors", false,
+		"Report files that are skipped, are not PNGs, or fail to parse and carry\n"+
+			"on with the rest, exiting with status 0. This is the default")
+	var nullDelimited bool
+	flag.BoolVar(&nullDelimited, "null-delimited", false,
+		"Separate -tags output records with null bytes, for use with xargs -0")
+	flag.BoolVar(&nullDelimited, "0", false, "Shorthand for -null-delimited")
+	configPath := flag.String("config", "",
+		"Read default flag values from the JSON object in the file at `path`.\n"+
+			"Defaults to "+defaultConfig+" in the current directory")
+	lineLimit := flag.Int("line-limit", 120,
+		"Show at most `n` characters of any chunk's data in -tags output")
+	noLimit := flag.Bool("no-limit", false,
+		"Show chunk data in full in -tags output, ignoring -line-limit")
+	showProgress := flag.Bool("progress", false,
+		"Print a running count of the images handled to stderr")
+	sortBySize := flag.Bool("sort-by-size", false,
+		"Process images smallest first")
+	sortByName := flag.Bool("sort-by-name", false,
+		"Process images in alphabetical order of their paths")
+	forceColor := flag.Bool("color", false,
+		"Color chunk types in -tags output even when not writing to a terminal")
+	noColor := flag.Bool("no-color", false,
+		"Never color -tags output. By default it is colored when writing to a\n"+
+			"terminal")
+	assertDims := flag.String("assert-dimensions", "",
+		"Exit with status 1 if any image is not exactly `WxH` pixels, e.g.\n"+
+			"1920x1080")
+	assertNoExifData := flag.Bool("assert-no-exif", false,
+		"Exit with status 3 if any image contains EXIF data")
+	maxMetaRatio := flag.Float64("assert-max-metadata-ratio", 0,
+		"Exit with status 1 if metadata chunks make up more than `fraction` of\n"+
+			"any image's file size, e.g. 0.10 for 10%")
+	flag.BoolVar(&siSizes, "si", false,
+		"Print sizes with SI prefixes (1 KB = 1000 bytes) instead of binary ones")
 	flag.Usage = Usage
+
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
+	config, required := *configPath, true
+	if config == "" {
+		config, required = defaultConfig, false
+	}
+	if err := loadConfig(flag.CommandLine, config, required); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *strict && *ignoreErrors {
+		fmt.Fprintln(os.Stderr, "-strict and -ignore-errors cannot be used together")
+		os.Exit(1)
+	}
+
+	if *forceColor && *noColor {
+		fmt.Fprintln(os.Stderr, "-color and -no-color cannot be used together")
+		os.Exit(1)
+	}
+	color := *forceColor || !*noColor && ansi.Supported(os.Stdout)
+
+	limit := *lineLimit
+	if *noLimit {
+		limit = 0
+	}
+
+	// fail ends the run after a file could not be handled, unless errors are
+	// being ignored
+	fail := func() {
+		if *strict {
+			os.Exit(2)
+		}
+	}
+
 	args := flag.Args()
+	opts := &options{
+		verbose:      *verbose,
+		cleanFile:    *cleanFile,
+		reproducible: *reproducible,
+		normalize:    *normalize,
+		stripText:    *stripText,
+		keepKeywords: keepKeywords,
+		output:       *output,
+	}
+
+	if len(keepKeywords) > 0 && !*stripText {
+		fmt.Fprintln(os.Stderr, "-keep-keyword requires -strip-text")
+		os.Exit(1)
+	}
+
+	var wantDims *dimensions
+	if *assertDims != "" {
+		d, err := parseDimensions(*assertDims)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -assert-dimensions: %v\n", err)
+			os.Exit(1)
+		}
+		wantDims = &d
+	}
+
+	// A ratio of 0 asserts there is no metadata, so check whether the flag was
+	// given rather than whether it is set
+	var checkMetaRatio bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "assert-max-metadata-ratio" {
+			checkMetaRatio = true
+		}
+	})
+	if checkMetaRatio && (*maxMetaRatio < 0 || *maxMetaRatio > 1) {
+		fmt.Fprintln(os.Stderr,
+			"-assert-max-metadata-ratio must be between 0 and 1")
+		os.Exit(1)
+	}
+
+	var extractDest string
+	if *extractChunk != "" {
+		if len(args) == 0 || len(args) > 2 {
+			fmt.Fprintln(os.Stderr,
+				"-extract-chunk takes a destination file and at most one image")
+			os.Exit(1)
+		}
+		extractDest, args = args[0], args[1:]
+	}
+
+	if *extractICC != "" && len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "-extract-icc takes at most one image")
+		os.Exit(1)
+	}
+
+	if *injectChunk != "" {
+		if *output == "" || len(args) == 0 {
+			fmt.Fprintln(os.Stderr,
+				"-inject-chunk takes a source file and requires -output")
+			os.Exit(1)
+		}
+
+		ch, err := loadChunk(*injectChunk, args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to inject chunk: %v\n", err)
+			os.Exit(1)
+		}
+		opts.injected, args = &ch, args[1:]
+	}
+
+	if *copyMetaFrom != "" {
+		if *output == "" {
+			fmt.Fprintln(os.Stderr, "-copy-meta-from requires -output")
+			os.Exit(1)
+		}
+
+		src, err := parseFile(*copyMetaFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to read metadata source: %v\n", err)
+			os.Exit(1)
+		}
+		opts.metaSource = src
+	}
+
+	if len(args) > 0 {
+		if args = expandGlobs(args); len(args) == 0 {
+			return
+		}
+	}
+
+	switch {
+	case *sortBySize && *sortByName:
+		fmt.Fprintln(os.Stderr,
+			"-sort-by-size and -sort-by-name cannot be used together")
+		os.Exit(1)
+	case *sortBySize:
+		sortBySizes(args)
+	case *sortByName:
+		sort.Strings(args)
+	}
+
+	if *output != "" && len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "-output takes a single image")
+		os.Exit(1)
+	}
+
+	if *watchDir != "" {
+		if !*cleanFile || *output != "" {
+			fmt.Fprintln(os.Stderr, "-watch requires -clean and cannot use -output")
+			os.Exit(1)
+		}
+		if err := watch(*watchDir, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to watch %s: %v\n", *watchDir, err)
+			os.Exit(1)
+		}
+		return
+	}
 
+	var sizeLimit int64
+	if *maxSize != "" {
+		n, err := parseSize(*maxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -max-size: %v\n", err)
+			os.Exit(1)
+		}
+		sizeLimit = n
+	}
+
+	var batch Batch
 	if len(args) == 0 {
 		parsers = append(parsers, png.New("stdin", os.Stdin))
 	} else {
 		for _, path := range args {
+			if sizeLimit > 0 {
+				info, err := os.Stat(path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "unable to open files: %v\n", err)
+					batch = append(batch, BatchResult{Path: path, Err: err})
+					fail()
+					continue
+				}
+				if info.Size() > sizeLimit {
+					fmt.Fprintf(os.Stderr, "skipping %s: %d bytes is over -max-size\n",
+						path, info.Size())
+					fail()
+					continue
+				}
+			}
+
 			f, err := os.Open(path)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "unable to open files: %v", err)
-				return
+				fmt.Fprintf(os.Stderr, "unable to open files: %v\n", err)
+				batch = append(batch, BatchResult{Path: path, Err: err})
+				fail()
+				continue
 			}
 
 			parsers = append(parsers, png.New(path, f))
@@ -42,74 +357,496 @@ func main() {
 		}
 	}()
 
+	var progress *progressReporter
+	if *showProgress {
+		progress = newProgressReporter(os.Stderr, len(parsers))
+	}
+
+	// status is the exit status once every image is handled, set when an
+	// assertion fails. Finding EXIF data takes precedence over other failures.
+	status := 0
+
 	for i, p := range parsers {
+		if progress != nil {
+			progress.report(p.Path)
+		}
+
 		if b, err := p.IsPNG(); !b || err != nil {
 			fmt.Fprintf(os.Stderr, "%s is not a PNG\n", p.Path)
+			batch = append(batch, BatchResult{
+				Path: p.Path,
+				Err:  errors.New("not a PNG"),
+			})
+			fail()
 			continue
 		}
 
 		if err := p.Parse(); err != nil {
-			fmt.Fprintf(os.Stderr, "problem parsing %s: %v", p.Path, err)
+			fmt.Fprintf(os.Stderr, "problem parsing %s: %v\n", p.Path, err)
+			batch = append(batch, BatchResult{Path: p.Path, Err: err})
+			fail()
 			continue
 		}
 
-		if *showTags {
-			fmt.Fprintf(os.Stdout, "%s tags:\n", p.Path)
-			p.WalkChunks(func(ch png.Chunk) bool {
-				if !(ch.Type == png.ChunkTypeData || ch.Type == png.ChunkTypeHeader || ch.Type == png.ChunkTypeEnd) {
-					fmt.Fprintf(os.Stdout, "  %s\n", ch.Type)
+		if wantDims != nil {
+			if err := assertDimensions(p, *wantDims); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				if status == 0 {
+					status = 1
 				}
-				if ch.Type == png.ChunkTypeTxtUTF8 || ch.Type == png.ChunkTypeTxtISO8859 {
-					fmt.Fprintf(os.Stdout, "   %s\n", ch.Data)
+			}
+		}
+
+		if checkMetaRatio {
+			if err := assertMetadataRatio(p, *maxMetaRatio); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				if status == 0 {
+					status = 1
 				}
-				return true
+			}
+		}
+
+		if *assertNoExifData {
+			if err := assertNoExif(p); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = exitExifPresent
+			}
+		}
+
+		if *showTags {
+			printTags(os.Stdout, p, tagOptions{
+				verbose:       *verbose,
+				base64:        *base64Data,
+				nullDelimited: nullDelimited,
+				color:         color,
+				lineLimit:     limit,
 			})
 		}
 
-		if *cleanFile {
-			var destPath string
+		if *summary {
+			printSummary(os.Stdout, p)
+		}
+
+		if *extractChunk != "" {
+			os.Exit(extractChunkData(p, *extractChunk, extractDest))
+		}
+
+		if *extractICC != "" {
+			os.Exit(extractProfile(p, *extractICC))
+		}
+
+		if *hexdumpChunk != "" {
+			if len(*hexdumpChunk) != 4 {
+				fmt.Fprintf(os.Stderr, "unable to dump chunk: chunk type %q is not "+
+					"4 characters\n", *hexdumpChunk)
+				os.Exit(1)
+			}
 
-			if p.Path == "stdin" {
-				if wd, err := os.Getwd(); err != nil {
-					fmt.Fprintf(os.Stderr, "unable to determine current directory: %v",
-						err)
-					os.Exit(1)
-				} else {
-					destPath = path.Join(wd, fmt.Sprintf("stdin-%d.png", i))
+			// Match on the type bytes so that unknown and private chunks can be
+			// dumped
+			var dump *png.Chunk
+			p.WalkChunks(func(ch png.Chunk) bool {
+				if ch.RawType() == *hexdumpChunk {
+					dump = &ch
 				}
-			} else {
-				name := path.Base(p.Path)
-				base := path.Dir(p.Path)
-				parts := strings.Split(name, ".")
-				destPath = path.Join(
-					base,
-					strings.Join(parts[:len(parts)-1], ".")+"-cleaned"+".png",
-				)
+				return dump == nil
+			})
 
+			if dump == nil {
+				fmt.Fprintf(os.Stderr, "%s has no %s chunk\n", p.Path, *hexdumpChunk)
+			} else {
+				name := dump.Type.String()
+				if dump.IsUnknown() {
+					name = fmt.Sprintf("%s (%s)", dump.Type, dump.RawType())
+				}
+				fmt.Fprintf(os.Stdout, "%s %s:\n", p.Path, name)
+				if err := dump.Hexdump(os.Stdout); err != nil {
+					fmt.Fprintf(os.Stderr, "unable to dump chunk for %s: %v\n",
+						p.Path, err)
+				}
 			}
+		}
+
+
+		res, err := process(p, i, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		batch = append(batch, res)
+	}
+
+	if progress != nil {
+		progress.finish()
+	}
+
+	if opts.cleanFile && len(batch) > 1 {
+		batch.Format(os.Stderr, "text")
+	}
+
+	if status != 0 {
+		os.Exit(status)
+	}
+}
+
+// process applies the requested modifications to a parsed image, writes out
+// the results, and reports what was done. i is the position of the image among
+// the inputs.
+func process(p *png.Parser, i int, opts *options) (BatchResult, error) {
+	res := BatchResult{Path: p.Path, ChunksFound: p.Stats().Chunks}
+	if size, err := p.SizeOnDisk(); err == nil {
+		res.InputSize = size
+	}
+
+	if opts.metaSource != nil {
+		merged, err := copyMetadata(p, opts.metaSource)
+		if err != nil {
+			return res, fmt.Errorf("unable to copy metadata into %s: %v",
+				p.Path, err)
+		}
+		p = merged
+	}
 
-			dest, err :=
-				os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if opts.injected != nil {
+		if err := p.InsertChunk(*opts.injected); err != nil {
+			return res, fmt.Errorf("unable to inject chunk into %s: %v",
+				p.Path, err)
+		}
+	}
+
+	if opts.reproducible {
+		profile := png.NormalizationProfile{
+			StripTime:    true,
+			StripPrivate: true,
+			Canonical:    true,
+		}
+		if err := p.Normalize(profile); err != nil {
+			return res, fmt.Errorf("unable to normalize %s: %v", p.Path, err)
+		}
+	}
+
+	if opts.normalize {
+		if err := p.Normalize(png.DefaultNormalization); err != nil {
+			return res, fmt.Errorf("unable to normalize %s: %v", p.Path, err)
+		}
+	}
 
+	if opts.stripText {
+		keep := png.FilterTextKeywords(opts.keepKeywords...)
+		err := p.TransformChunks(func(ch png.Chunk) (png.Chunk, bool, error) {
+			return ch, !ch.IsText() || keep(ch), nil
+		})
+		if err != nil {
+			return res, fmt.Errorf("unable to strip text from %s: %v", p.Path, err)
+		}
+	}
+
+	if opts.cleanFile {
+		var destPath string
+
+		if p.Path == "stdin" {
+			wd, err := os.Getwd()
 			if err != nil {
-				fmt.Fprintf(os.Stderr,
-					"unable to open file cleaning destination for %s: %v\n", p.Path, err)
-				os.Exit(1)
+				return res, fmt.Errorf("unable to determine current directory: %v", err)
 			}
+			destPath = filepath.Join(wd, fmt.Sprintf("stdin-%d.png", i))
+		} else {
+			name := filepath.Base(p.Path)
+			base := filepath.Dir(p.Path)
+			destPath = filepath.Join(
+				base,
+				strings.TrimSuffix(name, filepath.Ext(name))+"-cleaned"+".png",
+			)
+		}
 
-			if _, err := io.Copy(dest, p.StripTags()); err != nil && err != io.EOF {
-				fmt.Fprintf(os.Stderr, "unable to strip tags for %s: %v", p.Path, err)
-				os.Exit(1)
-			}
+		dest, err :=
+			os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY, 0644)
+
+		if err != nil {
+			return res, fmt.Errorf(
+				"unable to open file cleaning destination for %s: %v", p.Path, err)
+		}
 
+		written, err := io.Copy(dest, p.StripTags())
+		if err != nil && err != io.EOF {
 			dest.Close()
+			return res, fmt.Errorf("unable to strip tags for %s: %v", p.Path, err)
+		}
+
+		dest.Close()
+
+		p.WalkChunks(func(ch png.Chunk) bool {
+			if png.FilterStrippable(ch) {
+				res.ChunksStripped++
+			}
+			return true
+		})
+		res.OutputSize = written
+
+		if opts.verbose {
+			reportSaving(p, destPath, written)
+		}
+	}
+
+	if opts.output != "" {
+		if err := writeImage(p, opts.output); err != nil {
+			return res, fmt.Errorf("unable to write %s: %v", opts.output, err)
+		}
+	}
+
+	return res, nil
+}
+
+// reportSaving prints how much smaller the cleaned copy of the image is.
+func reportSaving(p *png.Parser, dest string, written int64) {
+	size, err := p.SizeOnDisk()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wrote %s (%s)\n", dest, formatSize(written))
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "wrote %s (%s, saved %s)\n", dest,
+		formatSize(written), formatSize(size-written))
+}
+
+// expandGlobs replaces arguments holding * or ? wildcards with the paths they
+// match, for shells such as those on Windows that pass patterns through
+// unexpanded. Patterns matching nothing are dropped with a warning.
+func expandGlobs(args []string) []string {
+	var paths []string
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?") {
+			paths = append(paths, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid pattern %s: %v\n", arg, err)
+			continue
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "warning: no files match %s\n", arg)
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+
+	return paths
+}
+
+// sortBySizes orders paths by the size of the files they name, smallest first.
+// Paths that cannot be read sort last, keeping their order, so that they are
+// reported when opened.
+func sortBySizes(paths []string) {
+	sizes := make(map[string]int64, len(paths))
+	for _, path := range paths {
+		sizes[path] = -1
+		if info, err := os.Stat(path); err == nil {
+			sizes[path] = info.Size()
+		}
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		a, b := sizes[paths[i]], sizes[paths[j]]
+		if a < 0 || b < 0 {
+			return b < 0 && a >= 0
+		}
+		return a < b
+	})
+}
+
+// parseSize reads a byte count with an optional B, KB, MB, or GB suffix. The
+// suffixes are multiples of 1024 and are not case sensitive.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	num, mult := strings.TrimSpace(s), int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(strings.ToUpper(num), u.suffix) {
+			num, mult = strings.TrimSpace(num[:len(num)-len(u.suffix)]), u.size
+			break
 		}
 	}
+
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read size %q", s)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("size %q is negative", s)
+	}
+
+	return n * mult, nil
+}
+
+// extractChunkData writes the data of the first chunk of the given type to
+// dest. It returns the exit code for the program: 2 if there is no such chunk,
+// 1 if it could not be written, and 0 on success.
+func extractChunkData(p *png.Parser, fourcc, dest string) int {
+	if len(fourcc) != 4 {
+		fmt.Fprintf(os.Stderr, "unable to extract chunk: chunk type %q is not "+
+			"4 characters\n", fourcc)
+		return 2
+	}
+
+	// Match on the type bytes so that unknown and private chunks can be found
+	var data []byte
+	found := false
+	p.WalkChunks(func(ch png.Chunk) bool {
+		if ch.RawType() == fourcc {
+			data, found = ch.Data, true
+		}
+		return !found
+	})
+	if !found {
+		fmt.Fprintf(os.Stderr, "%s has no %s chunk\n", p.Path, fourcc)
+		return 2
+	}
+
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write %s chunk to %s: %v\n",
+			fourcc, dest, err)
+		return 1
+	}
+
+	return 0
+}
+
+// extractProfile writes the decompressed ICC profile of the image to dest and
+// prints the profile's name to stderr. It returns the exit code for the
+// program: 2 if there is no iCCP chunk, 1 if the profile could not be decoded
+// or written, and 0 on success.
+func extractProfile(p *png.Parser, dest string) int {
+	chunks := p.GetChunksByType(png.ChunkTypeICC)
+	if len(chunks) == 0 {
+		fmt.Fprintf(os.Stderr, "%s has no iCCP chunk\n", p.Path)
+		return 2
+	}
+
+	iccp, err := png.ParseICCPChunk(chunks[0].Data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to parse iCCP chunk: %v\n", err)
+		return 1
+	}
+
+	profile, err := iccp.Profile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := os.WriteFile(dest, profile, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write ICC profile to %s: %v\n",
+			dest, err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, iccp.Name)
+	return 0
+}
+
+// loadChunk builds an ancillary chunk of the type named by fourcc holding the
+// contents of the file at src.
+func loadChunk(fourcc, src string) (png.Chunk, error) {
+	if fourcc != "" && fourcc[0] >= 'A' && fourcc[0] <= 'Z' {
+		return png.Chunk{}, fmt.Errorf("chunk type %q is critical", fourcc)
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return png.Chunk{}, err
+	}
+
+	return png.NewRawChunk([]byte(fourcc), data)
+}
+
+// parseFile opens and parses the PNG at path.
+func parseFile(path string) (*png.Parser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := png.New(path, f)
+	if b, err := p.IsPNG(); !b || err != nil {
+		return nil, fmt.Errorf("%s is not a PNG", path)
+	}
+	if err := p.Parse(); err != nil {
+		return nil, fmt.Errorf("problem parsing %s: %v", path, err)
+	}
+
+	return p, nil
+}
+
+// copyMetadata combines the critical chunks of p with the ancillary chunks of
+// src using png.MergePNGs, returning a parser over the merged image. It fails
+// if the images are not compatible. The copied chunk types are reported on
+// stderr.
+func copyMetadata(p, src *png.Parser) (*png.Parser, error) {
+	var buf bytes.Buffer
+	if err := png.MergePNGs(p, src, &buf); err != nil {
+		return nil, err
+	}
+
+	merged := png.New(p.Path, io.NopCloser(&buf))
+	if err := merged.Parse(); err != nil {
+		return nil, fmt.Errorf("unable to read merged image: %v", err)
+	}
+
+	var copied []string
+	seen := make(map[string]bool)
+	merged.WalkChunks(func(ch png.Chunk) bool {
+		if t := ch.Type.Abbreviation(); ch.IsAncillary() && !seen[t] {
+			seen[t] = true
+			copied = append(copied, t)
+		}
+		return true
+	})
+	fmt.Fprintf(os.Stderr, "copied from %s: %s\n", src.Path,
+		strings.Join(copied, ", "))
+
+	return merged, nil
+}
+
+// writeImage writes the parsed image to the file at dest.
+func writeImage(p *png.Parser, dest string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
 }
 
 // Usage adds a bit of customization to the standard flag package Usage helper
 func Usage() {
 	fmt.Fprintf(os.Stderr, "usage: pnguin [imgpath ...]\n")
+	fmt.Fprintf(os.Stderr, "       pnguin <command> [args]\n")
 	flag.PrintDefaults()
+
+	fmt.Fprintf(os.Stderr, "\ncommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s%s\n", name, commands[name].summary)
+	}
 	os.Exit(2)
 }
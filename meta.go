@@ -0,0 +1,443 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"gitlab.com/thedahv/pnguin/png"
+)
+
+// metadata is the JSON form of an image's metadata used by export-meta and
+// import-meta. Keys left out of a file are not known or not to be changed.
+type metadata struct {
+	Header         *headerMeta `json:"header,omitempty"`
+	Text           []textMeta  `json:"text,omitempty"`
+	Time           *time.Time  `json:"time,omitempty"`
+	Gamma          *float64    `json:"gamma,omitempty"`
+	DPI            *dpiMeta    `json:"dpi,omitempty"`
+	ICCProfile     *string     `json:"iccProfile,omitempty"`
+	SRGBIntent     *string     `json:"srgbIntent,omitempty"`
+	Chromaticities *chrmMeta   `json:"chromaticities,omitempty"`
+
+	HasExif            *bool `json:"hasExif,omitempty"`
+	HasTransparency    *bool `json:"hasTransparency,omitempty"`
+	HasSignificantBits *bool `json:"hasSignificantBits,omitempty"`
+}
+
+// headerMeta holds the IHDR fields
+type headerMeta struct {
+	Width             uint32 `json:"width"`
+	Height            uint32 `json:"height"`
+	BitDepth          byte   `json:"bitDepth"`
+	ColorType         byte   `json:"colorType"`
+	CompressionMethod byte   `json:"compressionMethod"`
+	FilterMethod      byte   `json:"filterMethod"`
+	InterlaceMethod   byte   `json:"interlaceMethod"`
+}
+
+// textMeta holds one tEXt, zTXt, or iTXt chunk
+type textMeta struct {
+	Type              string `json:"type"`
+	Keyword           string `json:"keyword"`
+	Text              string `json:"text"`
+	LanguageTag       string `json:"languageTag,omitempty"`
+	TranslatedKeyword string `json:"translatedKeyword,omitempty"`
+}
+
+// dpiMeta holds the resolution from pHYs in dots per inch
+type dpiMeta struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// chrmMeta holds the white point and primaries from cHRM
+type chrmMeta struct {
+	WhiteX float64 `json:"whiteX"`
+	WhiteY float64 `json:"whiteY"`
+	RedX   float64 `json:"redX"`
+	RedY   float64 `json:"redY"`
+	GreenX float64 `json:"greenX"`
+	GreenY float64 `json:"greenY"`
+	BlueX  float64 `json:"blueX"`
+	BlueY  float64 `json:"blueY"`
+}
+
+// runExportMeta implements the export-meta command, writing the metadata of an
+// image to a JSON file.
+func runExportMeta(args []string) int {
+	fs := flag.NewFlagSet("export-meta", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pnguin export-meta imgpath meta.json")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	p, err := parseFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	meta, err := collectMetadata(p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read metadata of %s: %v\n", p.Path, err)
+		return 1
+	}
+
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create %s: %v\n", fs.Arg(1), err)
+		return 1
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(meta); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write %s: %v\n", fs.Arg(1), err)
+		return 1
+	}
+
+	return 0
+}
+
+// metresPerInch converts the DPI of the JSON form to the pixels per metre
+// stored in pHYs
+const metresPerInch = 0.0254
+
+// runImportMeta implements the import-meta command, applying the metadata in a
+// JSON file to an image and writing the result to a new file.
+func runImportMeta(args []string) int {
+	fs := flag.NewFlagSet("import-meta", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr,
+			"usage: pnguin import-meta imgpath meta.json destpath")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return 2
+	}
+
+	p, err := parseFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	meta, err := readMetadata(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := applyMetadata(p, meta); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to apply %s to %s: %v\n", fs.Arg(1),
+			p.Path, err)
+		return 1
+	}
+
+	if err := writeImage(p, fs.Arg(2)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// readMetadata loads a JSON metadata file. Unknown keys are rejected so that
+// typos are not silently ignored.
+func readMetadata(path string) (metadata, error) {
+	var meta metadata
+
+	f, err := os.Open(path)
+	if err != nil {
+		return meta, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&meta); err != nil {
+		return meta, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+
+	return meta, nil
+}
+
+// applyMetadata replaces the metadata of p with the fields set in meta. Each
+// field replaces all chunks of its type, and chunk types without a field are
+// left alone:
+//
+//   - the header is informational and is not applied
+//   - iccProfile renames the existing profile, since the profile data itself is
+//     not part of the JSON
+//   - the has* fields can only remove chunks: false strips them, true keeps
+//     whatever the image already has
+func applyMetadata(p *png.Parser, meta metadata) error {
+	var added []png.Chunk
+	replaced := make(map[string]bool)
+	replace := func(ch png.Chunk, err error) error {
+		if err != nil {
+			return err
+		}
+		replaced[ch.Type.Abbreviation()] = true
+		added = append(added, ch)
+		return nil
+	}
+
+	if meta.Text != nil {
+		replaced[png.ChunkTypeTxtISO8859.Abbreviation()] = true
+		replaced[png.ChunkTypeTxtCompressed.Abbreviation()] = true
+		replaced[png.ChunkTypeTxtUTF8.Abbreviation()] = true
+	}
+	for _, txt := range meta.Text {
+		var ch png.Chunk
+		var err error
+		switch txt.Type {
+		case "tEXt":
+			ch, err = png.NewTextChunk(txt.Keyword, txt.Text)
+		case "zTXt":
+			ch, err = png.NewZTXtChunk(txt.Keyword, txt.Text)
+		case "iTXt":
+			ch, err = png.NewITXtChunk(txt.Keyword, txt.LanguageTag,
+				txt.TranslatedKeyword, txt.Text, false)
+		default:
+			return fmt.Errorf("unknown text chunk type %q", txt.Type)
+		}
+		if err := replace(ch, err); err != nil {
+			return fmt.Errorf("unable to build %s chunk %q: %v", txt.Type,
+				txt.Keyword, err)
+		}
+	}
+
+	if meta.Time != nil {
+		if err := replace(png.NewTimeChunk(*meta.Time)); err != nil {
+			return err
+		}
+	}
+
+	if meta.Gamma != nil {
+		if err := replace(png.NewGammaChunk(*meta.Gamma)); err != nil {
+			return err
+		}
+	}
+
+	if meta.DPI != nil {
+		x := math.Round(meta.DPI.X / metresPerInch)
+		y := math.Round(meta.DPI.Y / metresPerInch)
+		if !(x >= 0 && x <= math.MaxUint32 && y >= 0 && y <= math.MaxUint32) {
+			return fmt.Errorf("resolution %vx%v DPI is out of range",
+				meta.DPI.X, meta.DPI.Y)
+		}
+		err := replace(png.NewPhysChunk(uint32(x), uint32(y), png.UnitMetre))
+		if err != nil {
+			return err
+		}
+	}
+
+	if meta.ICCProfile != nil {
+		chunks := p.GetChunksByType(png.ChunkTypeICC)
+		if len(chunks) == 0 {
+			fmt.Fprintf(os.Stderr, "%s has no ICC profile to rename, "+
+				"skipping iccProfile\n", p.Path)
+		} else {
+			iccp, err := png.ParseICCPChunk(chunks[0].Data)
+			if err != nil {
+				return err
+			}
+			profile, err := iccp.Profile()
+			if err != nil {
+				return err
+			}
+			if err := replace(png.NewICCPChunk(*meta.ICCProfile,
+				profile)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if meta.SRGBIntent != nil {
+		intent, err := parseRenderingIntent(*meta.SRGBIntent)
+		if err != nil {
+			return err
+		}
+		if err := replace(png.NewSRGBChunk(intent)); err != nil {
+			return err
+		}
+	}
+
+	if c := meta.Chromaticities; c != nil {
+		err := replace(png.NewCHRMChunk(c.WhiteX, c.WhiteY, c.RedX, c.RedY,
+			c.GreenX, c.GreenY, c.BlueX, c.BlueY))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range []struct {
+		has  *bool
+		name string
+	}{
+		{meta.HasExif, png.ChunkTypeExif.Abbreviation()},
+		{meta.HasTransparency, png.ChunkTypeTransparency.Abbreviation()},
+		{meta.HasSignificantBits, png.ChunkTypeSigBits.Abbreviation()},
+	} {
+		if f.has != nil && !*f.has {
+			replaced[f.name] = true
+		}
+	}
+
+	err := p.TransformChunks(func(ch png.Chunk) (png.Chunk, bool, error) {
+		return ch, ch.IsUnknown() || !replaced[ch.Type.Abbreviation()], nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, ch := range added {
+		if err := p.InsertChunk(ch); err != nil {
+			return err
+		}
+	}
+	p.Canonicalize()
+
+	return nil
+}
+
+// parseRenderingIntent looks up a rendering intent by the name it is exported
+// under.
+func parseRenderingIntent(name string) (png.RenderingIntent, error) {
+	for _, ri := range []png.RenderingIntent{
+		png.RenderingIntentPerceptual,
+		png.RenderingIntentRelativeColorimetric,
+		png.RenderingIntentSaturation,
+		png.RenderingIntentAbsoluteColorimetric,
+	} {
+		if ri.String() == name {
+			return ri, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown rendering intent %q", name)
+}
+
+// collectMetadata decodes the metadata chunks of p. Only the first chunk of
+// each type that may appear once is used.
+func collectMetadata(p *png.Parser) (metadata, error) {
+	var meta metadata
+
+	hdr, err := p.Header()
+	if err != nil {
+		return meta, err
+	}
+	meta.Header = &headerMeta{
+		Width:             hdr.Width,
+		Height:            hdr.Height,
+		BitDepth:          hdr.BitDepth,
+		ColorType:         hdr.ColorType,
+		CompressionMethod: hdr.CompressionMethod,
+		FilterMethod:      hdr.FilterMethod,
+		InterlaceMethod:   hdr.InterlaceMethod,
+	}
+
+	for _, ch := range p.FilterChunks(png.FilterTextChunks) {
+		txt := textMeta{Type: ch.Type.Abbreviation()}
+		switch ch.Type {
+		case png.ChunkTypeTxtISO8859, png.ChunkTypeTxtCompressed:
+			parse := png.ParseTextChunk
+			if ch.Type == png.ChunkTypeTxtCompressed {
+				parse = png.ParseZTXtChunk
+			}
+			t, err := parse(ch.Data)
+			if err != nil {
+				return meta, fmt.Errorf("unable to parse %s chunk: %v", txt.Type, err)
+			}
+			txt.Keyword, txt.Text = t.Keyword, t.Text
+		case png.ChunkTypeTxtUTF8:
+			t, err := png.ParseITXtChunk(ch.Data)
+			if err != nil {
+				return meta, fmt.Errorf("unable to parse iTXt chunk: %v", err)
+			}
+			txt.Keyword, txt.Text = t.Keyword, t.Text
+			txt.LanguageTag, txt.TranslatedKeyword = t.LanguageTag,
+				t.TranslatedKeyword
+		}
+		meta.Text = append(meta.Text, txt)
+	}
+
+	if ch, ok := firstChunk(p, "tIME"); ok {
+		t, err := png.ParseTimeChunk(ch.Data)
+		if err != nil {
+			return meta, err
+		}
+		meta.Time = &t
+	}
+
+	if ch, ok := firstChunk(p, "gAMA"); ok {
+		gamma, err := png.ParseGammaChunk(ch.Data)
+		if err != nil {
+			return meta, err
+		}
+		meta.Gamma = &gamma
+	}
+
+	if ch, ok := firstChunk(p, "pHYs"); ok {
+		phys, err := png.ParsePhysChunk(ch.Data)
+		if err != nil {
+			return meta, err
+		}
+		if x, y, ok := phys.DPI(); ok {
+			meta.DPI = &dpiMeta{X: x, Y: y}
+		}
+	}
+
+	if ch, ok := firstChunk(p, "iCCP"); ok {
+		iccp, err := png.ParseICCPChunk(ch.Data)
+		if err != nil {
+			return meta, err
+		}
+		meta.ICCProfile = &iccp.Name
+	}
+
+	if ch, ok := firstChunk(p, "sRGB"); ok {
+		intent, err := png.ParseSRGBChunk(ch.Data)
+		if err != nil {
+			return meta, err
+		}
+		name := intent.String()
+		meta.SRGBIntent = &name
+	}
+
+	if ch, ok := firstChunk(p, "cHRM"); ok {
+		chrm, err := png.ParseCHRMChunk(ch.Data)
+		if err != nil {
+			return meta, err
+		}
+		meta.Chromaticities = &chrmMeta{
+			WhiteX: chrm.WhiteX, WhiteY: chrm.WhiteY,
+			RedX: chrm.RedX, RedY: chrm.RedY,
+			GreenX: chrm.GreenX, GreenY: chrm.GreenY,
+			BlueX: chrm.BlueX, BlueY: chrm.BlueY,
+		}
+	}
+
+	hasExif := hasChunk(p, "eXIf")
+	hasTransparency := hasChunk(p, "tRNS")
+	hasSignificantBits := hasChunk(p, "sBIT")
+	meta.HasExif = &hasExif
+	meta.HasTransparency = &hasTransparency
+	meta.HasSignificantBits = &hasSignificantBits
+
+	return meta, nil
+}
@@ -0,0 +1,521 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// UnitSpecifier describes the unit used by the pixel dimensions in a pHYs
+// chunk.
+type UnitSpecifier byte
+
+// Valid values for the pHYs unit specifier byte
+const (
+	UnitUnknown UnitSpecifier = 0
+	UnitMetre   UnitSpecifier = 1
+)
+
+// String converts unit specifiers to a human-friendly representation
+func (u UnitSpecifier) String() string {
+	switch u {
+	case UnitUnknown:
+		return "unknown"
+	case UnitMetre:
+		return "metre"
+	default:
+		return fmt.Sprintf("invalid (%d)", byte(u))
+	}
+}
+
+// PhysChunk gives us a breakdown of the pHYs chunk, which holds the intended
+// pixel size or aspect ratio of the image.
+// It contains (in this order) the pixels per unit on the X axis, pixels per
+// unit on the Y axis, and the unit specifier (9 data bytes total)
+type PhysChunk struct {
+	PixelsPerUnitX uint32
+	PixelsPerUnitY uint32
+	Unit           UnitSpecifier
+}
+
+// ParsePhysChunk decodes the data of a pHYs chunk.
+func ParsePhysChunk(chunk []byte) (PhysChunk, error) {
+	var phys PhysChunk
+	if l := len(chunk); l != 9 {
+		return phys, fmt.Errorf("got %d bytes for pHYs chunk, expected %d",
+			l, 9)
+	}
+
+	phys.PixelsPerUnitX = binary.BigEndian.Uint32(chunk[0:4])
+	phys.PixelsPerUnitY = binary.BigEndian.Uint32(chunk[4:8])
+	phys.Unit = UnitSpecifier(chunk[8])
+
+	if phys.Unit != UnitUnknown && phys.Unit != UnitMetre {
+		return phys, fmt.Errorf("invalid pHYs unit specifier %d", chunk[8])
+	}
+
+	return phys, nil
+}
+
+// NewPhysChunk builds a pHYs chunk giving the pixels per unit along each axis.
+func NewPhysChunk(x, y uint32, unit UnitSpecifier) (Chunk, error) {
+	if unit != UnitUnknown && unit != UnitMetre {
+		return Chunk{}, fmt.Errorf("invalid pHYs unit specifier %d", byte(unit))
+	}
+
+	data := make([]byte, 9)
+	binary.BigEndian.PutUint32(data[0:4], x)
+	binary.BigEndian.PutUint32(data[4:8], y)
+	data[8] = byte(unit)
+
+	return NewChunk(ChunkTypePxSize, data)
+}
+
+// inchesPerMetre converts pixels per metre to pixels per inch
+const inchesPerMetre = 39.3701
+
+// DPI gives the resolution in dots per inch along each axis. It reports false
+// when the unit is unknown, in which case the chunk only gives an aspect ratio.
+func (phys PhysChunk) DPI() (x, y float64, ok bool) {
+	if phys.Unit != UnitMetre {
+		return 0, 0, false
+	}
+
+	return float64(phys.PixelsPerUnitX) / inchesPerMetre,
+		float64(phys.PixelsPerUnitY) / inchesPerMetre, true
+}
+
+// gammaScale is the factor gAMA values are stored multiplied by
+const gammaScale = 100000
+
+// ParseGammaChunk decodes the data of a gAMA chunk, returning the image gamma.
+// This is the exponent that was used to encode the samples, so it is the
+// inverse of the display gamma: 0.45455 for a 2.2 display.
+func ParseGammaChunk(chunk []byte) (float64, error) {
+	if l := len(chunk); l != 4 {
+		return 0, fmt.Errorf("got %d bytes for gAMA chunk, expected %d", l, 4)
+	}
+
+	return float64(binary.BigEndian.Uint32(chunk)) / gammaScale, nil
+}
+
+// NewGammaChunk builds a gAMA chunk holding the image gamma, which must be
+// positive and is stored to five decimal places.
+func NewGammaChunk(gamma float64) (Chunk, error) {
+	scaled := math.Round(gamma * gammaScale)
+	if !(scaled >= 1 && scaled <= math.MaxUint32) {
+		return Chunk{}, fmt.Errorf("gamma %v is out of range", gamma)
+	}
+
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, uint32(scaled))
+	return NewChunk(ChunkTypeGamma, data)
+}
+
+// ParseHistogramChunk decodes the data of a hIST chunk, returning the
+// approximate usage frequency of each palette entry in palette order.
+func ParseHistogramChunk(chunk []byte) ([]uint16, error) {
+	if l := len(chunk); l%2 != 0 {
+		return nil, fmt.Errorf("got %d bytes for hIST chunk, expected an even "+
+			"number", l)
+	}
+
+	freqs := make([]uint16, len(chunk)/2)
+	for i := range freqs {
+		freqs[i] = binary.BigEndian.Uint16(chunk[i*2:])
+	}
+
+	return freqs, nil
+}
+
+// chromaticityScale is the factor cHRM values are stored multiplied by
+const chromaticityScale = 100000
+
+// Chromaticities holds the CIE 1931 x and y chromaticities of the white point
+// and the red, green, and blue primaries stored in a cHRM chunk.
+type Chromaticities struct {
+	WhiteX, WhiteY float64
+	RedX, RedY     float64
+	GreenX, GreenY float64
+	BlueX, BlueY   float64
+}
+
+// ParseCHRMChunk decodes the data of a cHRM chunk.
+func ParseCHRMChunk(chunk []byte) (Chromaticities, error) {
+	var c Chromaticities
+	if l := len(chunk); l != 32 {
+		return c, fmt.Errorf("got %d bytes for cHRM chunk, expected %d", l, 32)
+	}
+
+	values := []*float64{&c.WhiteX, &c.WhiteY, &c.RedX, &c.RedY, &c.GreenX,
+		&c.GreenY, &c.BlueX, &c.BlueY}
+	for i, v := range values {
+		*v = float64(binary.BigEndian.Uint32(chunk[i*4:])) / chromaticityScale
+	}
+
+	return c, nil
+}
+
+// NewCHRMChunk builds a cHRM chunk holding the CIE 1931 x and y chromaticities
+// of the white point and the red, green, and blue primaries. Each value must be
+// in [0, 1].
+func NewCHRMChunk(whiteX, whiteY, redX, redY, greenX, greenY, blueX,
+	blueY float64) (Chunk, error) {
+	values := []float64{whiteX, whiteY, redX, redY, greenX, greenY, blueX, blueY}
+
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		if !(v >= 0 && v <= 1) {
+			return Chunk{}, fmt.Errorf("chromaticity %v is outside [0, 1]", v)
+		}
+
+		// Values in range scale to at most 100000, well within a uint32
+		binary.BigEndian.PutUint32(data[i*4:],
+			uint32(math.Round(v*chromaticityScale)))
+	}
+
+	return NewChunk(ChunkTypeChromaticity, data)
+}
+
+// NewBKGDGrayscaleChunk builds a bKGD chunk for a grayscale image, with or
+// without alpha, of the given bit depth. The gray level must fit in that many
+// bits.
+func NewBKGDGrayscaleChunk(gray uint16, bitDepth byte) (Chunk, error) {
+	switch bitDepth {
+	case 1, 2, 4, 8, 16:
+	default:
+		return Chunk{}, fmt.Errorf("bit depth %d is not valid for grayscale",
+			bitDepth)
+	}
+	if bitDepth < 16 && gray >= 1<<bitDepth {
+		return Chunk{}, fmt.Errorf("gray level %d does not fit in %d bits", gray,
+			bitDepth)
+	}
+
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, gray)
+	return NewChunk(ChunkTypeBkgdColor, data)
+}
+
+// NewBKGDTruecolorChunk builds a bKGD chunk for a truecolor image, with or
+// without alpha, of the given bit depth. Each sample must fit in that many
+// bits.
+func NewBKGDTruecolorChunk(r, g, b uint16, bitDepth byte) (Chunk, error) {
+	switch bitDepth {
+	case 8, 16:
+	default:
+		return Chunk{}, fmt.Errorf("bit depth %d is not valid for truecolor",
+			bitDepth)
+	}
+	if bitDepth == 8 && (r > 0xff || g > 0xff || b > 0xff) {
+		return Chunk{}, fmt.Errorf("color %d,%d,%d does not fit in %d bits", r, g,
+			b, bitDepth)
+	}
+
+	data := make([]byte, 6)
+	binary.BigEndian.PutUint16(data[0:2], r)
+	binary.BigEndian.PutUint16(data[2:4], g)
+	binary.BigEndian.PutUint16(data[4:6], b)
+	return NewChunk(ChunkTypeBkgdColor, data)
+}
+
+// NewBKGDIndexedChunk builds a bKGD chunk for an indexed color image, naming
+// the palette entry to use as the background. Validate checks that the index
+// is within the palette.
+func NewBKGDIndexedChunk(paletteIndex uint8) (Chunk, error) {
+	return NewChunk(ChunkTypeBkgdColor, []byte{paletteIndex})
+}
+
+// SPLTEntry is a single color of a suggested palette. Samples are scaled to the
+// palette's sample depth.
+type SPLTEntry struct {
+	Red, Green, Blue, Alpha uint16
+	Frequency               uint16
+}
+
+// SPLTChunk gives us a breakdown of the sPLT chunk, which suggests a reduced
+// palette to use when the display cannot show all of the image's colors.
+// It contains (in this order) the null-terminated palette name, the sample
+// depth (8 or 16), and the palette entries.
+type SPLTChunk struct {
+	Name        string
+	SampleDepth byte
+	Entries     []SPLTEntry
+}
+
+// ParseSPLTChunk decodes the data of an sPLT chunk.
+func ParseSPLTChunk(chunk []byte) (SPLTChunk, error) {
+	var splt SPLTChunk
+
+	i := bytes.IndexByte(chunk, 0)
+	if i < 0 {
+		return splt, fmt.Errorf("no null separator after sPLT palette name")
+	}
+	if i+1 >= len(chunk) {
+		return splt, fmt.Errorf("sPLT chunk missing sample depth")
+	}
+
+	splt.Name = latin1(chunk[:i])
+	splt.SampleDepth = chunk[i+1]
+	entries := chunk[i+2:]
+
+	var size int
+	switch splt.SampleDepth {
+	case 8:
+		size = 6
+	case 16:
+		size = 10
+	default:
+		return splt, fmt.Errorf("invalid sPLT sample depth %d", splt.SampleDepth)
+	}
+	if len(entries)%size != 0 {
+		return splt, fmt.Errorf("got %d bytes of sPLT entries, expected a "+
+			"multiple of %d", len(entries), size)
+	}
+
+	splt.Entries = make([]SPLTEntry, 0, len(entries)/size)
+	for ; len(entries) > 0; entries = entries[size:] {
+		var e SPLTEntry
+		if size == 6 {
+			e.Red, e.Green = uint16(entries[0]), uint16(entries[1])
+			e.Blue, e.Alpha = uint16(entries[2]), uint16(entries[3])
+			e.Frequency = binary.BigEndian.Uint16(entries[4:6])
+		} else {
+			e.Red = binary.BigEndian.Uint16(entries[0:2])
+			e.Green = binary.BigEndian.Uint16(entries[2:4])
+			e.Blue = binary.BigEndian.Uint16(entries[4:6])
+			e.Alpha = binary.BigEndian.Uint16(entries[6:8])
+			e.Frequency = binary.BigEndian.Uint16(entries[8:10])
+		}
+		splt.Entries = append(splt.Entries, e)
+	}
+
+	return splt, nil
+}
+
+// ParseTimeChunk decodes the data of a tIME chunk, which holds the time of the
+// last image modification in UTC as a two byte year followed by single bytes
+// for the month, day, hour, minute, and second (7 data bytes total).
+func ParseTimeChunk(chunk []byte) (time.Time, error) {
+	if l := len(chunk); l != 7 {
+		return time.Time{}, fmt.Errorf("got %d bytes for tIME chunk, expected %d",
+			l, 7)
+	}
+
+	year := int(binary.BigEndian.Uint16(chunk[0:2]))
+	month, day, hour, min, sec := chunk[2], chunk[3], chunk[4], chunk[5], chunk[6]
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 ||
+		min > 59 || sec > 60 {
+		return time.Time{}, fmt.Errorf("invalid tIME value %04d-%02d-%02d "+
+			"%02d:%02d:%02d", year, month, day, hour, min, sec)
+	}
+
+	// Leap seconds are allowed by the spec but not by time.Time, which
+	// normalizes them into the following minute
+	return time.Date(year, time.Month(month), int(day), int(hour), int(min),
+		int(sec), 0, time.UTC), nil
+}
+
+// NewTimeChunk builds a tIME chunk recording t, converted to UTC, as the time
+// of the last image modification. Fractions of a second are dropped.
+func NewTimeChunk(t time.Time) (Chunk, error) {
+	t = t.UTC()
+	if t.Year() < 0 || t.Year() > math.MaxUint16 {
+		return Chunk{}, fmt.Errorf("year %d cannot be stored in a tIME chunk",
+			t.Year())
+	}
+
+	data := make([]byte, 7)
+	binary.BigEndian.PutUint16(data[0:2], uint16(t.Year()))
+	data[2] = byte(t.Month())
+	data[3] = byte(t.Day())
+	data[4] = byte(t.Hour())
+	data[5] = byte(t.Minute())
+	data[6] = byte(t.Second())
+
+	return NewChunk(ChunkTypeTimeChanged, data)
+}
+
+// ICCPChunk gives us a breakdown of the iCCP chunk, which embeds an ICC color
+// profile. It contains (in this order) the null-terminated profile name, the
+// compression method, and the compressed profile.
+type ICCPChunk struct {
+	Name              string
+	CompressionMethod byte
+	CompressedProfile []byte
+}
+
+// ParseICCPChunk decodes the data of an iCCP chunk. The profile itself is left
+// compressed.
+func ParseICCPChunk(chunk []byte) (ICCPChunk, error) {
+	var iccp ICCPChunk
+
+	i := bytes.IndexByte(chunk, 0)
+	if i < 0 {
+		return iccp, fmt.Errorf("no null separator after iCCP profile name")
+	}
+	if i+1 >= len(chunk) {
+		return iccp, fmt.Errorf("iCCP chunk missing compression method")
+	}
+
+	iccp.Name = latin1(chunk[:i])
+	iccp.CompressionMethod = chunk[i+1]
+	iccp.CompressedProfile = chunk[i+2:]
+
+	if iccp.CompressionMethod != 0 {
+		return iccp, fmt.Errorf("unknown iCCP compression method %d",
+			iccp.CompressionMethod)
+	}
+
+	return iccp, nil
+}
+
+// Profile decompresses the embedded ICC profile.
+func (iccp ICCPChunk) Profile() ([]byte, error) {
+	profile, err := inflate(iccp.CompressedProfile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress ICC profile: %v", err)
+	}
+
+	return profile, nil
+}
+
+// RenderingIntent describes how colors outside the sRGB gamut should be
+// mapped when displaying an image holding an sRGB chunk.
+type RenderingIntent byte
+
+// Valid values for the sRGB rendering intent byte
+const (
+	RenderingIntentPerceptual           RenderingIntent = 0
+	RenderingIntentRelativeColorimetric RenderingIntent = 1
+	RenderingIntentSaturation           RenderingIntent = 2
+	RenderingIntentAbsoluteColorimetric RenderingIntent = 3
+)
+
+// String converts rendering intents to a human-friendly representation
+func (ri RenderingIntent) String() string {
+	switch ri {
+	case RenderingIntentPerceptual:
+		return "Perceptual"
+	case RenderingIntentRelativeColorimetric:
+		return "Relative Colorimetric"
+	case RenderingIntentSaturation:
+		return "Saturation"
+	case RenderingIntentAbsoluteColorimetric:
+		return "Absolute Colorimetric"
+	default:
+		return fmt.Sprintf("invalid (%d)", byte(ri))
+	}
+}
+
+// maxProfileName is the longest profile name an iCCP chunk may hold
+const maxProfileName = 79
+
+// NewICCPChunk builds an iCCP chunk embedding the ICC profile under the given
+// name, which must be 1 to 79 Latin-1 characters. The profile is compressed
+// with zlib at the best compression level.
+func NewICCPChunk(name string, profile []byte) (Chunk, error) {
+	if len(profile) == 0 {
+		return Chunk{}, errors.New("ICC profile is empty")
+	}
+	if strings.IndexByte(name, 0) >= 0 {
+		return Chunk{}, errors.New("ICC profile name contains a null byte")
+	}
+
+	encoded, err := encodeLatin1(name)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("invalid ICC profile name: %v", err)
+	}
+	if l := len(encoded); l < 1 || l > maxProfileName {
+		return Chunk{}, fmt.Errorf("ICC profile name is %d bytes, must be 1 to %d",
+			l, maxProfileName)
+	}
+
+	var data bytes.Buffer
+	data.Write(encoded)
+	data.Write([]byte{0, 0})
+
+	zw, err := zlib.NewWriterLevel(&data, zlib.BestCompression)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("unable to compress ICC profile: %v", err)
+	}
+	if _, err := zw.Write(profile); err != nil {
+		return Chunk{}, fmt.Errorf("unable to compress ICC profile: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return Chunk{}, fmt.Errorf("unable to compress ICC profile: %v", err)
+	}
+
+	return NewChunk(ChunkTypeICC, data.Bytes())
+}
+
+// ParseSRGBChunk decodes the data of an sRGB chunk, returning its rendering
+// intent.
+func ParseSRGBChunk(chunk []byte) (RenderingIntent, error) {
+	if l := len(chunk); l != 1 {
+		return 0, fmt.Errorf("got %d bytes for sRGB chunk, expected %d", l, 1)
+	}
+
+	intent := RenderingIntent(chunk[0])
+	if intent > RenderingIntentAbsoluteColorimetric {
+		return intent, fmt.Errorf("invalid sRGB rendering intent %d", chunk[0])
+	}
+
+	return intent, nil
+}
+
+// ErrInvalidRenderingIntent is returned when building an sRGB chunk with a
+// rendering intent outside the four the spec defines.
+var ErrInvalidRenderingIntent = errors.New("invalid sRGB rendering intent")
+
+// NewSRGBChunk builds an sRGB chunk declaring that the image uses the sRGB
+// color space with the given rendering intent.
+func NewSRGBChunk(intent RenderingIntent) (Chunk, error) {
+	if intent > RenderingIntentAbsoluteColorimetric {
+		return Chunk{}, ErrInvalidRenderingIntent
+	}
+
+	return NewChunk(ChunkTypeRGB, []byte{byte(intent)})
+}
+
+// ColorSpaceSummary describes how the image's colors should be interpreted,
+// combining the sRGB, iCCP, gAMA, and cHRM chunks. An sRGB chunk takes
+// precedence, then an embedded ICC profile; images with neither are reported
+// as unmanaged along with any gamma or chromaticity information.
+func (p *Parser) ColorSpaceSummary() string {
+	if ch, ok := p.firstChunk(ChunkTypeRGB); ok {
+		intent, err := ParseSRGBChunk(ch.Data)
+		if err != nil {
+			return "sRGB (invalid rendering intent)"
+		}
+		return fmt.Sprintf("sRGB (%s)", intent)
+	}
+
+	if ch, ok := p.firstChunk(ChunkTypeICC); ok {
+		iccp, err := ParseICCPChunk(ch.Data)
+		if err != nil {
+			return "ICC Profile: invalid"
+		}
+		return fmt.Sprintf("ICC Profile: %s", iccp.Name)
+	}
+
+	var notes []string
+	if ch, ok := p.firstChunk(ChunkTypeGamma); ok {
+		if gamma, err := ParseGammaChunk(ch.Data); err == nil {
+			notes = append(notes, fmt.Sprintf("gAMA: %.5f", gamma))
+		}
+	}
+	if p.hasChunk(ChunkTypeChromaticity) {
+		notes = append(notes, "cHRM")
+	}
+
+	if len(notes) == 0 {
+		return "Unmanaged"
+	}
+	return fmt.Sprintf("Unmanaged (%s)", strings.Join(notes, ", "))
+}
@@ -0,0 +1,402 @@
+package png
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+// PNG filter types, given by the byte that begins each scanline
+const (
+	filterNone    = 0
+	filterSub     = 1
+	filterUp      = 2
+	filterAverage = 3
+	filterPaeth   = 4
+)
+
+// pass gives the starting column and row of the pixels sent in one pass over
+// the image and the column and row spacing between them
+type pass struct{ x, y, dx, dy int }
+
+// adam7Passes are the seven passes of an Adam7 interlaced image
+var adam7Passes = []pass{
+	{0, 0, 8, 8},
+	{4, 0, 8, 8},
+	{0, 4, 4, 8},
+	{2, 0, 4, 4},
+	{0, 2, 2, 4},
+	{1, 0, 2, 2},
+	{0, 1, 1, 2},
+}
+
+// samplesPerPixel gives the number of samples per pixel for each color type
+var samplesPerPixel = map[byte]int{0: 1, 2: 3, 3: 1, 4: 2, 6: 4}
+
+// ToImage decodes the image data: it decompresses the IDAT chunks, reverses
+// the scanline filters, and undoes Adam7 interlacing. Grayscale images without
+// transparency give an *image.Gray (or *image.Gray16 at 16 bits), and all
+// others an *image.NRGBA (or *image.NRGBA64). Samples of fewer than 8 bits are
+// scaled up to 8. Headers giving a bit depth their color type does not allow
+// are rejected.
+func (p *Parser) ToImage() (image.Image, error) {
+	d, err := p.newDecoder()
+	if err != nil {
+		return nil, err
+	}
+	img, err := d.allocate(int(d.hdr.Width), int(d.hdr.Height))
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.decode(p, func(x, y int, line []byte, col int) error {
+		c, err := d.pixel(line, col)
+		if err != nil {
+			return err
+		}
+		img.Set(x, y, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// ColorAt decodes the single pixel at (x, y), reading the image data only as
+// far as needed to reach it. Colors are given in the same model ToImage uses:
+// color.Gray or color.Gray16 for grayscale images without transparency,
+// color.NRGBA64 for other 16 bit images, and color.NRGBA for the rest,
+// including indexed color images, whose alpha comes from tRNS.
+func (p *Parser) ColorAt(x, y int) (color.Color, error) {
+	if err := p.ValidateCoordinate(x, y); err != nil {
+		return nil, err
+	}
+	d, err := p.newDecoder()
+	if err != nil {
+		return nil, err
+	}
+
+	var found color.Color
+	err = d.decode(p, func(px, py int, line []byte, col int) error {
+		if px != x || py != y {
+			return nil
+		}
+		c, err := d.pixel(line, col)
+		if err != nil {
+			return err
+		}
+		found = c
+		return errStopDecoding
+	})
+	if err != nil && err != errStopDecoding {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// errStopDecoding is returned by a pixel visitor to end decoding early
+var errStopDecoding = errors.New("decoding stopped")
+
+// decoder holds the state needed to turn scanlines into pixels
+type decoder struct {
+	hdr     headerChunk
+	palette []color.RGBA
+	trns    []byte
+}
+
+// newDecoder gathers the chunks needed to interpret the image data.
+func (p *Parser) newDecoder() (*decoder, error) {
+	hdr, err := p.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &decoder{hdr: hdr}
+	if chunks := p.GetChunksByType(ChunkTypeTransparency); len(chunks) > 0 {
+		d.trns = chunks[0].Data
+	}
+	if hdr.ColorType == 3 {
+		chunks := p.GetChunksByType(ChunkTypePalette)
+		if len(chunks) == 0 {
+			return nil, fmt.Errorf("no PLTE chunk for indexed color image")
+		}
+		if d.palette, err = ParsePaletteChunk(chunks[0].Data); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// maxDecodePixels limits the size of the images ToImage will allocate memory
+// for
+const maxDecodePixels = 1 << 28
+
+// allocate creates an image of the given size to store decoded pixels in.
+func (d *decoder) allocate(width, height int) (draw.Image, error) {
+	if uint64(width)*uint64(height) > maxDecodePixels {
+		return nil, fmt.Errorf("image is %dx%d, too large to decode", width,
+			height)
+	}
+
+	r := image.Rect(0, 0, width, height)
+	deep := d.hdr.BitDepth == 16
+	switch {
+	case d.hdr.ColorType == 0 && d.trns == nil && deep:
+		return image.NewGray16(r), nil
+	case d.hdr.ColorType == 0 && d.trns == nil:
+		return image.NewGray(r), nil
+	case deep:
+		return image.NewNRGBA64(r), nil
+	default:
+		return image.NewNRGBA(r), nil
+	}
+}
+
+// decode reads the image data of p, handing each pixel to visit along with
+// the unfiltered scanline holding it and its column in that scanline. Pixels
+// are visited in the order they are stored, so interlaced images are visited
+// pass by pass. Decoding stops at the first error visit returns, which is
+// passed on as is.
+func (d *decoder) decode(p *Parser,
+	visit func(x, y int, line []byte, col int) error) error {
+	zr, err := p.DecompressedIDATReader()
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	passes := []pass{{0, 0, 1, 1}}
+	if d.hdr.InterlaceMethod == 1 {
+		passes = adam7Passes
+	}
+
+	for _, ps := range passes {
+		if err := d.decodePass(zr, ps, visit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodePass reads the scanlines of one pass from r and visits its pixels.
+// Non-interlaced images are a single pass covering every pixel.
+func (d *decoder) decodePass(r io.Reader, ps pass,
+	visit func(x, y int, line []byte, col int) error) error {
+	return d.readPass(r, ps, func(row, cols int, line, prev []byte) error {
+		for col := 0; col < cols; col++ {
+			err := visit(ps.x+col*ps.dx, ps.y+row*ps.dy, line, col)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readPass reads and unfilters the scanlines of one pass from r, handing each
+// to fn along with its row in the pass, the number of pixels it holds, and the
+// previous unfiltered scanline, which is all zeros for the first. The slices
+// are reused between calls.
+func (d *decoder) readPass(r io.Reader, ps pass,
+	fn func(row, cols int, line, prev []byte) error) error {
+	width, height := int(d.hdr.Width), int(d.hdr.Height)
+	if ps.x >= width || ps.y >= height {
+		return nil
+	}
+	cols := (width - ps.x + ps.dx - 1) / ps.dx
+	rows := (height - ps.y + ps.dy - 1) / ps.dy
+
+	bitsPerPixel := samplesPerPixel[d.hdr.ColorType] * int(d.hdr.BitDepth)
+	stride := (cols*bitsPerPixel + 7) / 8
+
+	cur := make([]byte, 1+stride)
+	prev := make([]byte, 1+stride)
+	for row := 0; row < rows; row++ {
+		if _, err := io.ReadFull(r, cur); err != nil {
+			return fmt.Errorf("unable to decode image data: unable to read "+
+				"scanline %d: %v", row, err)
+		}
+		if err := unfilter(cur[0], cur[1:], prev[1:], d.bpp()); err != nil {
+			return fmt.Errorf("unable to decode image data: scanline %d: %v",
+				row, err)
+		}
+
+		if err := fn(row, cols, cur[1:], prev[1:]); err != nil {
+			return err
+		}
+		cur, prev = prev, cur
+	}
+
+	return nil
+}
+
+// bpp gives the number of bytes per complete pixel, rounded up to 1, which is
+// the distance filters look back along a scanline.
+func (d *decoder) bpp() int {
+	return (samplesPerPixel[d.hdr.ColorType]*int(d.hdr.BitDepth) + 7) / 8
+}
+
+// unfilter reverses the filter applied to a scanline in place. prev is the
+// previous scanline of the pass after unfiltering, or all zeros for the first,
+// and bpp is the number of bytes per complete pixel, rounded up to 1.
+func unfilter(ft byte, cur, prev []byte, bpp int) error {
+	switch ft {
+	case filterNone:
+	case filterSub:
+		for i := bpp; i < len(cur); i++ {
+			cur[i] += cur[i-bpp]
+		}
+	case filterUp:
+		for i := range cur {
+			cur[i] += prev[i]
+		}
+	case filterAverage:
+		for i := range cur {
+			var left int
+			if i >= bpp {
+				left = int(cur[i-bpp])
+			}
+			cur[i] += byte((left + int(prev[i])) / 2)
+		}
+	case filterPaeth:
+		for i := range cur {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = cur[i-bpp], prev[i-bpp]
+			}
+			cur[i] += paeth(left, prev[i], upLeft)
+		}
+	default:
+		return fmt.Errorf("unknown filter type %d", ft)
+	}
+
+	return nil
+}
+
+// paeth picks whichever of the left, upper, and upper left bytes is closest to
+// left + up - upLeft, preferring them in that order on ties.
+func paeth(left, up, upLeft byte) byte {
+	p := int(left) + int(up) - int(upLeft)
+	pa, pb, pc := abs(p-int(left)), abs(p-int(up)), abs(p-int(upLeft))
+	switch {
+	case pa <= pb && pa <= pc:
+		return left
+	case pb <= pc:
+		return up
+	default:
+		return upLeft
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// sample reads the i-th sample of an unfiltered scanline.
+func (d *decoder) sample(line []byte, i int) uint16 {
+	switch depth := int(d.hdr.BitDepth); depth {
+	case 16:
+		return binary.BigEndian.Uint16(line[i*2:])
+	case 8:
+		return uint16(line[i])
+	default:
+		bit := i * depth
+		shift := 8 - depth - bit%8
+		return uint16(line[bit/8]>>shift) & (1<<depth - 1)
+	}
+}
+
+// pixel decodes the color of the pixel at column col of an unfiltered
+// scanline.
+func (d *decoder) pixel(line []byte, col int) (color.Color, error) {
+	n := samplesPerPixel[d.hdr.ColorType]
+	s := make([]uint16, n)
+	for i := range s {
+		s[i] = d.sample(line, col*n+i)
+	}
+
+	if d.hdr.ColorType == 3 {
+		if int(s[0]) >= len(d.palette) {
+			return nil, fmt.Errorf("palette index %d is out of range for %d "+
+				"PLTE entries", s[0], len(d.palette))
+		}
+		c := d.palette[s[0]]
+		alpha := uint8(0xff)
+		if int(s[0]) < len(d.trns) {
+			alpha = d.trns[s[0]]
+		}
+		return color.NRGBA{c.R, c.G, c.B, alpha}, nil
+	}
+
+	// Samples are compared against tRNS before being scaled, since tRNS holds
+	// values at the image's bit depth
+	opaque := true
+	switch d.hdr.ColorType {
+	case 0:
+		opaque = len(d.trns) < 2 || binary.BigEndian.Uint16(d.trns) != s[0]
+	case 2:
+		opaque = len(d.trns) < 6 ||
+			binary.BigEndian.Uint16(d.trns[0:]) != s[0] ||
+			binary.BigEndian.Uint16(d.trns[2:]) != s[1] ||
+			binary.BigEndian.Uint16(d.trns[4:]) != s[2]
+	}
+
+	if d.hdr.BitDepth == 16 {
+		var c color.NRGBA64
+		switch d.hdr.ColorType {
+		case 0:
+			if d.trns == nil {
+				return color.Gray16{s[0]}, nil
+			}
+			c = color.NRGBA64{s[0], s[0], s[0], 0xffff}
+		case 2:
+			c = color.NRGBA64{s[0], s[1], s[2], 0xffff}
+		case 4:
+			c = color.NRGBA64{s[0], s[0], s[0], s[1]}
+		case 6:
+			c = color.NRGBA64{s[0], s[1], s[2], s[3]}
+		}
+		if !opaque {
+			c.A = 0
+		}
+		return c, nil
+	}
+
+	// Scale samples of fewer than 8 bits to the full 8 bit range
+	max := uint16(1)<<d.hdr.BitDepth - 1
+	v := make([]uint8, n)
+	for i := range s {
+		v[i] = uint8(uint32(s[i]) * 0xff / uint32(max))
+	}
+
+	var c color.NRGBA
+	switch d.hdr.ColorType {
+	case 0:
+		if d.trns == nil {
+			return color.Gray{v[0]}, nil
+		}
+		c = color.NRGBA{v[0], v[0], v[0], 0xff}
+	case 2:
+		c = color.NRGBA{v[0], v[1], v[2], 0xff}
+	case 4:
+		c = color.NRGBA{v[0], v[0], v[0], v[1]}
+	case 6:
+		c = color.NRGBA{v[0], v[1], v[2], v[3]}
+	}
+	if !opaque {
+		c.A = 0
+	}
+	return c, nil
+}
@@ -0,0 +1,104 @@
+package png
+
+import (
+	"fmt"
+	"io"
+)
+
+// TruncationError reports input that ended partway through a field of a chunk.
+type TruncationError struct {
+	// Field names the part of the chunk being read: length, type, data, or CRC
+	Field string
+	// Offset is the position in the file of the chunk being read
+	Offset int64
+	// Got is the number of bytes read before the input ended
+	Got int
+	// Expected is the size of the field
+	Expected int
+}
+
+func (e *TruncationError) Error() string {
+	return fmt.Sprintf("truncated chunk %s at offset 0x%x (got %d bytes, "+
+		"expected %d)", e.Field, e.Offset, e.Got, e.Expected)
+}
+
+// ParseError reports input that cannot be read as PNG chunks.
+type ParseError struct {
+	// Kind identifies the problem, e.g. "ChunkTooLarge"
+	Kind    string
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+// ValidationError reports a file that was read successfully but does not follow
+// the PNG spec.
+type ValidationError struct {
+	// Kind identifies the rule that was broken, e.g. "MissingIEND"
+	Kind    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+// OrderError reports a chunk that appears where the spec does not allow it,
+// either out of sequence or without a chunk it depends on.
+type OrderError struct {
+	// Type is the misplaced chunk
+	Type    chunkType
+	Message string
+}
+
+func (e *OrderError) Error() string {
+	return e.Message
+}
+
+// readField fills buf from r, returning a TruncationError if the input ends
+// before buf is full.
+func readField(r io.Reader, buf []byte, field string, offset int64) error {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF ||
+		err == nil && n != len(buf) {
+		return &TruncationError{
+			Field:    field,
+			Offset:   offset,
+			Got:      n,
+			Expected: len(buf),
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read chunk %s: %v", field, err)
+	}
+
+	return nil
+}
+
+// skipField discards n bytes from r, returning a TruncationError if the input
+// ends first.
+func skipField(r io.Reader, n int64, field string, offset int64) error {
+	return copyField(io.Discard, r, n, field, offset)
+}
+
+// copyField copies n bytes from r to w, returning a TruncationError if the
+// input ends first.
+func copyField(w io.Writer, r io.Reader, n int64, field string,
+	offset int64) error {
+	copied, err := io.CopyN(w, r, n)
+	if err == io.EOF {
+		return &TruncationError{
+			Field:    field,
+			Offset:   offset,
+			Got:      int(copied),
+			Expected: int(n),
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read chunk %s: %v", field, err)
+	}
+
+	return nil
+}
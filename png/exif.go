@@ -0,0 +1,183 @@
+package png
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// ExifData holds the basic fields recoverable from an eXIf chunk, which holds
+// an EXIF block laid out as a TIFF file: a byte order marker, the TIFF magic
+// number, and the offset of the first image file directory (IFD), followed by
+// the directory entries and their values.
+type ExifData struct {
+	// ByteOrder is the marker at the start of the block: "II" for little-endian
+	// or "MM" for big-endian
+	ByteOrder string
+	// Magic is the TIFF magic number, which is always 42 in valid data
+	Magic uint16
+	// Size is the length of the EXIF block in bytes
+	Size int
+
+	ImageWidth       uint32
+	ImageLength      uint32
+	Make             string
+	Model            string
+	DateTime         string
+	ImageDescription string
+	// ExifIFDPointer is the offset of the EXIF sub-IFD holding camera
+	// settings, or 0 if there is none
+	ExifIFDPointer uint32
+
+	// Other holds the entries of the first IFD whose tags are not decoded
+	// above, in the order they appear
+	Other []ExifTag
+}
+
+// ExifTag is a raw IFD entry: its tag, field type, and value bytes in the
+// byte order of the EXIF block.
+type ExifTag struct {
+	Tag   uint16
+	Type  uint16
+	Value []byte
+}
+
+// String formats the entry as its tag followed by its value in hex, e.g.
+// "tag 0x0131: [47 49 4d 50 00]".
+func (t ExifTag) String() string {
+	return fmt.Sprintf("tag 0x%04x: [% x]", t.Tag, t.Value)
+}
+
+// TIFF tags read from the first IFD
+const (
+	exifTagImageWidth  = 0x0100
+	exifTagImageLength = 0x0101
+	exifTagMake        = 0x010f
+	exifTagModel       = 0x0110
+	exifTagDateTime    = 0x0132
+
+	exifTagImageDescription = 0x010e
+	exifTagExifIFDPointer   = 0x8769
+)
+
+// TIFF field types read from IFD entries
+const (
+	exifTypeASCII = 2
+	exifTypeShort = 3
+	exifTypeLong  = 4
+)
+
+// exifTypeSizes gives the size in bytes of a single value of each TIFF field
+// type, indexed by type
+var exifTypeSizes = []uint64{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+// ParseExifChunk decodes the header and basic fields of the first IFD of an
+// eXIf chunk, keeping the raw values of any other entries. Parsing stops at
+// the first malformed structure; in that case the fields recovered up to that
+// point are returned along with the error.
+func ParseExifChunk(chunk []byte) (ExifData, error) {
+	exif := ExifData{Size: len(chunk)}
+	if len(chunk) < 8 {
+		return exif, fmt.Errorf("got %d bytes for EXIF header, expected %d",
+			len(chunk), 8)
+	}
+
+	var order binary.ByteOrder
+	switch exif.ByteOrder = string(chunk[:2]); exif.ByteOrder {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return exif, fmt.Errorf("invalid EXIF byte order marker %q", chunk[:2])
+	}
+
+	exif.Magic = order.Uint16(chunk[2:4])
+	if exif.Magic != 42 {
+		return exif, fmt.Errorf("invalid TIFF magic number %d", exif.Magic)
+	}
+
+	ifd := uint64(order.Uint32(chunk[4:8]))
+	if ifd+2 > uint64(len(chunk)) {
+		return exif, fmt.Errorf("IFD offset %d is beyond the end of the data", ifd)
+	}
+
+	count := uint64(order.Uint16(chunk[ifd:]))
+	for i := uint64(0); i < count; i++ {
+		start := ifd + 2 + i*12
+		if start+12 > uint64(len(chunk)) {
+			return exif, fmt.Errorf("IFD entry %d is beyond the end of the data", i)
+		}
+		entry := chunk[start : start+12]
+
+		tag := order.Uint16(entry[0:2])
+		typ := order.Uint16(entry[2:4])
+		n := uint64(order.Uint32(entry[4:8]))
+
+		if int(typ) >= len(exifTypeSizes) || exifTypeSizes[typ] == 0 {
+			exif.Other = append(exif.Other,
+				ExifTag{tag, typ, append([]byte(nil), entry[8:12]...)})
+			continue
+		}
+
+		// Values that fit in four bytes are held in the entry itself, otherwise
+		// the entry holds their offset
+		value := entry[8:12]
+		if size := exifTypeSizes[typ] * n; size > 4 {
+			offset := uint64(order.Uint32(entry[8:12]))
+			if offset+size > uint64(len(chunk)) {
+				return exif, fmt.Errorf("value of tag 0x%04x is beyond the end of "+
+					"the data", tag)
+			}
+			value = chunk[offset : offset+size]
+		} else {
+			value = value[:size]
+		}
+
+		switch tag {
+		case exifTagImageWidth:
+			exif.ImageWidth = exifUint(order, typ, value)
+		case exifTagImageLength:
+			exif.ImageLength = exifUint(order, typ, value)
+		case exifTagMake:
+			exif.Make = exifString(typ, value)
+		case exifTagModel:
+			exif.Model = exifString(typ, value)
+		case exifTagDateTime:
+			exif.DateTime = exifString(typ, value)
+		case exifTagImageDescription:
+			exif.ImageDescription = exifString(typ, value)
+		case exifTagExifIFDPointer:
+			exif.ExifIFDPointer = exifUint(order, typ, value)
+		default:
+			exif.Other = append(exif.Other,
+				ExifTag{tag, typ, append([]byte(nil), value...)})
+		}
+	}
+
+	return exif, nil
+}
+
+// exifString decodes an ASCII field value, which is null-terminated
+func exifString(typ uint16, value []byte) string {
+	if typ != exifTypeASCII {
+		return ""
+	}
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+
+	return string(value)
+}
+
+// exifUint decodes the first value of a SHORT or LONG field
+func exifUint(order binary.ByteOrder, typ uint16, value []byte) uint32 {
+	switch {
+	case typ == exifTypeShort && len(value) >= 2:
+		return uint32(order.Uint16(value))
+	case typ == exifTypeLong && len(value) >= 4:
+		return order.Uint32(value)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,85 @@
+package png
+
+// ChunkFilter reports whether a chunk should be selected.
+type ChunkFilter func(ch Chunk) bool
+
+// Predefined filters for common selections
+var (
+	// FilterCriticalOnly selects IHDR, PLTE, IDAT, and IEND.
+	FilterCriticalOnly ChunkFilter = func(ch Chunk) bool {
+		return ch.IsCritical()
+	}
+
+	// FilterAncillaryOnly selects every chunk that is not critical.
+	FilterAncillaryOnly ChunkFilter = func(ch Chunk) bool {
+		return ch.IsAncillary()
+	}
+
+	// FilterTextChunks selects tEXt, iTXt, and zTXt chunks.
+	FilterTextChunks ChunkFilter = func(ch Chunk) bool {
+		return ch.IsText()
+	}
+
+	// FilterMetadata selects the chunks that describe the image rather than
+	// make it up: every ancillary chunk, but never the palette.
+	FilterMetadata ChunkFilter = func(ch Chunk) bool {
+		return ch.IsAncillary() && ch.Type != ChunkTypePalette
+	}
+
+	// FilterSafeToCopy selects chunks whose safe-to-copy bit is set, meaning
+	// editors may carry them over into a modified image.
+	FilterSafeToCopy ChunkFilter = func(ch Chunk) bool {
+		return !ch.SafeToStrip()
+	}
+
+	// FilterStrippable selects the chunks StripTags removes: every ancillary
+	// chunk except unknown ones marked safe to copy.
+	FilterStrippable ChunkFilter = func(ch Chunk) bool {
+		return ch.IsAncillary() && !(ch.IsUnknown() && !ch.SafeToStrip())
+	}
+)
+
+// FilterTextKeywords returns a filter selecting the text chunks whose keyword
+// is one of keywords. Keywords are case-sensitive, and text chunks whose
+// keyword cannot be read are never selected.
+func FilterTextKeywords(keywords ...string) ChunkFilter {
+	set := make(map[string]bool, len(keywords))
+	for _, k := range keywords {
+		set[k] = true
+	}
+
+	return func(ch Chunk) bool {
+		if !ch.IsText() {
+			return false
+		}
+		key, _, err := textKeyword(ch.Data)
+		return err == nil && set[key]
+	}
+}
+
+// FilterChunks returns copies of the chunks selected by fn in the order they
+// appear in the file.
+func (p *Parser) FilterChunks(fn ChunkFilter) []Chunk {
+	var chunks []Chunk
+	for _, ch := range p.data {
+		if fn(ch) {
+			chunks = append(chunks, ch.Clone())
+		}
+	}
+
+	return chunks
+}
+
+// SelectChunks returns copies of the chunks matching any of the given types in
+// the order they appear in the file.
+func (p *Parser) SelectChunks(types ...chunkType) []Chunk {
+	set := make(map[chunkType]struct{}, len(types))
+	for _, ct := range types {
+		set[ct] = struct{}{}
+	}
+
+	return p.FilterChunks(func(ch Chunk) bool {
+		_, ok := set[ch.Type]
+		return ok
+	})
+}
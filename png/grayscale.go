@@ -0,0 +1,116 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Luminance weights of the red, green, and blue channels from ITU-R BT.709
+const (
+	lumaRed   = 0.2126
+	lumaGreen = 0.7152
+	lumaBlue  = 0.0722
+)
+
+// ToGrayscale returns a copy of the parser holding the image converted to
+// grayscale (color type 0) by luminance. The result is 16 bits deep if the
+// image is, and 8 bits otherwise. Alpha is dropped, so transparent pixels take
+// the gray level of their color. Chunks that describe the old colors or depend
+// on the color type are dropped: PLTE, the color space chunks, tRNS, bKGD,
+// sBIT, hIST, and sPLT, along with unknown chunks that are not safe to copy.
+// Text, tIME, and other metadata are kept.
+func (p *Parser) ToGrayscale() (*Parser, error) {
+	hdr, err := p.Header()
+	if err != nil {
+		return nil, err
+	}
+	img, err := p.ToImage()
+	if err != nil {
+		return nil, err
+	}
+
+	deep := hdr.BitDepth == 16
+	width, height := int(hdr.Width), int(hdr.Height)
+	bytesPerSample := 1
+	if deep {
+		bytesPerSample = 2
+	}
+
+	var raw bytes.Buffer
+	prev := make([]byte, width*bytesPerSample)
+	line := make([]byte, width*bytesPerSample)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			l := math.Round(lumaRed*float64(c.R) + lumaGreen*float64(c.G) +
+				lumaBlue*float64(c.B))
+			if deep {
+				binary.BigEndian.PutUint16(line[x*2:], uint16(l))
+			} else {
+				line[x] = uint8(math.Round(l / 0x101))
+			}
+		}
+
+		ft, filtered := chooseFilter(FilterStrategyMinimumSum, line, prev,
+			bytesPerSample)
+		raw.WriteByte(ft)
+		raw.Write(filtered)
+		line, prev = prev, line
+	}
+
+	var compressed bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compress image data: %v", err)
+	}
+	if _, err := zw.Write(raw.Bytes()); err != nil {
+		return nil, fmt.Errorf("unable to compress image data: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to compress image data: %v", err)
+	}
+
+	depth := byte(8)
+	if deep {
+		depth = 16
+	}
+	header, err := NewHeaderChunk(hdr.Width, hdr.Height, depth,
+		ColorTypeGrayscale, 0, 0, InterlaceNone)
+	if err != nil {
+		return nil, err
+	}
+
+	size := p.idatChunkSize
+	if size <= 0 {
+		size = defaultIDATChunkSize
+	}
+
+	var chunks []Chunk
+	dataWritten := false
+	for _, ch := range p.data {
+		switch {
+		case ch.Type == ChunkTypeHeader:
+			chunks = append(chunks, header)
+		case ch.Type == ChunkTypeData:
+			if !dataWritten {
+				chunks = append(chunks, splitData(compressed.Bytes(), size)...)
+				dataWritten = true
+			}
+		case ch.Type == ChunkTypePalette, ch.IsColorSpace(),
+			ch.Type == ChunkTypeTransparency, ch.Type == ChunkTypeBkgdColor,
+			ch.Type == ChunkTypeSigBits, ch.Type == ChunkTypeHistogram,
+			ch.Type == ChunkTypeSugPalette,
+			ch.IsUnknown() && ch.SafeToStrip():
+		default:
+			chunks = append(chunks, ch.Clone())
+		}
+	}
+
+	cp := p.Copy()
+	cp.data = chunks
+	return cp, nil
+}
@@ -0,0 +1,59 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// IDATReader returns a reader over the image data split across the IDAT chunks,
+// concatenated in file order. The result is the zlib stream that must be
+// decompressed as a whole to recover the scanlines.
+func (p *Parser) IDATReader() io.Reader {
+	var readers []io.Reader
+	for _, ch := range p.data {
+		if ch.Type == ChunkTypeData {
+			readers = append(readers, bytes.NewReader(ch.Data))
+		}
+	}
+
+	return io.MultiReader(readers...)
+}
+
+// DecompressedIDATReader returns a reader over the decompressed image data.
+// This is the raw scanline stream: each scanline is preceded by its filter type
+// byte and holds filtered samples, so the caller is responsible for applying
+// PNG filter reconstruction (and undoing interlacing) to get pixel values.
+// The caller should close the reader when done.
+func (p *Parser) DecompressedIDATReader() (io.ReadCloser, error) {
+	if !p.hasChunk(ChunkTypeData) {
+		return nil, errors.New("no IDAT chunks")
+	}
+
+	zr, err := zlib.NewReader(p.IDATReader())
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress image data: %v", err)
+	}
+
+	return zr, nil
+}
+
+// Fingerprint identifies the image by its pixel data alone: it gives the hex
+// SHA-256 hash of the IDAT data, so files that differ only in metadata share a
+// fingerprint. It is an error if there is no IDAT data to hash.
+func (p *Parser) Fingerprint() (string, error) {
+	if !p.hasChunk(ChunkTypeData) {
+		return "", errors.New("unable to fingerprint image: no IDAT chunks")
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, p.IDATReader()); err != nil {
+		return "", fmt.Errorf("unable to fingerprint image: %v", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
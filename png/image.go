@@ -0,0 +1,109 @@
+package png
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// ValidateCoordinate checks that the pixel at (x, y) lies within the image,
+// where (0, 0) is the top left corner. The header must have been parsed.
+func (p *Parser) ValidateCoordinate(x, y int) error {
+	hdr, err := p.Header()
+	if err != nil {
+		return err
+	}
+
+	if x < 0 || int64(x) >= int64(hdr.Width) ||
+		y < 0 || int64(y) >= int64(hdr.Height) {
+		return fmt.Errorf("coordinate (%d, %d) is outside the %dx%d image: x "+
+			"must be in [0, %d] and y in [0, %d]", x, y, hdr.Width, hdr.Height,
+			hdr.Width-1, hdr.Height-1)
+	}
+
+	return nil
+}
+
+// Thumbnail decodes the image and scales it down with nearest-neighbor
+// sampling to fit within maxWidth by maxHeight, preserving its aspect ratio.
+// Images that already fit are returned at full size. For interlaced images
+// whose first Adam7 pass, which holds every eighth pixel in each direction, is
+// at least as large as the thumbnail, only that pass is decoded.
+func (p *Parser) Thumbnail(maxWidth, maxHeight int) (image.Image, error) {
+	if maxWidth < 1 || maxHeight < 1 {
+		return nil, fmt.Errorf("thumbnail must be at least 1x1, got %dx%d",
+			maxWidth, maxHeight)
+	}
+
+	d, err := p.newDecoder()
+	if err != nil {
+		return nil, err
+	}
+	width, height := int(d.hdr.Width), int(d.hdr.Height)
+
+	scale := math.Min(1, math.Min(float64(maxWidth)/float64(width),
+		float64(maxHeight)/float64(height)))
+	w := int(math.Max(1, math.Round(float64(width)*scale)))
+	h := int(math.Max(1, math.Round(float64(height)*scale)))
+
+	first := adam7Passes[0]
+	cols := (width + first.dx - 1) / first.dx
+	rows := (height + first.dy - 1) / first.dy
+
+	var src image.Image
+	if d.hdr.InterlaceMethod == 1 && cols >= w && rows >= h {
+		src, err = p.firstPass(d, cols, rows)
+	} else {
+		src, err = p.ToImage()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	if b.Dx() == w && b.Dy() == h {
+		return src, nil
+	}
+
+	dst, err := d.allocate(w, h)
+	if err != nil {
+		return nil, err
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, src.At(x*b.Dx()/w, y*b.Dy()/h))
+		}
+	}
+
+	return dst, nil
+}
+
+// firstPass decodes only the first Adam7 pass of an interlaced image into an
+// image of cols by rows pixels.
+func (p *Parser) firstPass(d *decoder, cols, rows int) (image.Image, error) {
+	img, err := d.allocate(cols, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := p.DecompressedIDATReader()
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	first := adam7Passes[0]
+	err = d.decodePass(zr, first, func(x, y int, line []byte, col int) error {
+		c, err := d.pixel(line, col)
+		if err != nil {
+			return err
+		}
+		img.Set(x/first.dx, y/first.dy, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
@@ -0,0 +1,57 @@
+package png
+
+// Severities of lint warnings
+const (
+	// SeverityWarning marks chunks that are allowed but likely to be
+	// displayed inconsistently
+	SeverityWarning = "Warning"
+	// SeverityAdvisory marks chunks that are redundant but harmless
+	SeverityAdvisory = "Advisory"
+)
+
+// LintWarning describes a questionable but valid use of chunks.
+type LintWarning struct {
+	Severity string
+	Message  string
+}
+
+func (w LintWarning) String() string {
+	return w.Severity + ": " + w.Message
+}
+
+// Lint reports chunks that the spec permits but advises against. Unlike
+// Validate, nothing reported here makes the file invalid.
+func (p *Parser) Lint() []LintWarning {
+	var warnings []LintWarning
+
+	if p.hasChunk(ChunkTypeRGB) && p.hasChunk(ChunkTypeICC) {
+		warnings = append(warnings, LintWarning{
+			Severity: SeverityWarning,
+			Message: "both sRGB and iCCP are present and may call for " +
+				"conflicting color rendering",
+		})
+	}
+	if p.hasChunk(ChunkTypeRGB) && p.hasChunk(ChunkTypeGamma) {
+		warnings = append(warnings, LintWarning{
+			Severity: SeverityAdvisory,
+			Message:  "gAMA is superseded by sRGB",
+		})
+	}
+	if p.hasChunk(ChunkTypeRGB) && p.hasChunk(ChunkTypeChromaticity) {
+		warnings = append(warnings, LintWarning{
+			Severity: SeverityAdvisory,
+			Message:  "cHRM is superseded by sRGB",
+		})
+	}
+
+	for _, ch := range p.GetChunksByType(ChunkTypeGamma) {
+		if gamma, err := ParseGammaChunk(ch.Data); err == nil && gamma == 0 {
+			warnings = append(warnings, LintWarning{
+				Severity: SeverityWarning,
+				Message:  "gAMA value is zero and will be ignored",
+			})
+		}
+	}
+
+	return warnings
+}
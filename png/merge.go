@@ -0,0 +1,182 @@
+package png
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MergeMode controls how MergeMetadata treats chunk types that are present in
+// both parsers.
+type MergeMode int
+
+// Strategies for merging metadata chunks
+const (
+	// MergeSkip keeps the receiver's chunks and ignores incoming chunks of a
+	// type the receiver already has.
+	MergeSkip MergeMode = iota
+	// MergeReplace drops the receiver's chunks of any type being copied over.
+	MergeReplace
+	// MergeAppend copies every incoming chunk alongside the existing ones,
+	// except that chunk types the spec allows only once, such as gAMA and
+	// pHYs, are replaced as with MergeReplace.
+	MergeAppend
+)
+
+// MergeMetadata copies the ancillary chunks from other into the receiver. The
+// chunks are then sorted into the positions the spec requires, as Canonicalize
+// does, with copied chunks that may go anywhere placed just before IEND.
+// Chunks of an unknown type are not copied since there is no telling whether
+// they apply to the destination image.
+func (p *Parser) MergeMetadata(other *Parser, mode MergeMode) error {
+	if !p.parsed {
+		return errors.New("unable to merge metadata: destination not parsed")
+	}
+	if !other.parsed {
+		return errors.New("unable to merge metadata: source not parsed")
+	}
+
+	existing := make(map[chunkType]bool)
+	for _, ch := range p.data {
+		existing[ch.Type] = true
+	}
+
+	var incoming []Chunk
+	incomingTypes := make(map[chunkType]bool)
+	for _, ch := range other.data {
+		if ch.IsCritical() || ch.Type == ChunkTypeUnknown {
+			continue
+		}
+		if mode == MergeSkip && existing[ch.Type] {
+			continue
+		}
+
+		incoming = append(incoming, ch.Clone())
+		incomingTypes[ch.Type] = true
+	}
+
+	var (
+		merged   []Chunk
+		inserted bool
+	)
+	for _, ch := range p.data {
+		replace := mode == MergeReplace ||
+			mode == MergeAppend && singleInstance(ch.Type)
+		if replace && incomingTypes[ch.Type] {
+			continue
+		}
+		if ch.Type == ChunkTypeEnd && !inserted {
+			merged = append(merged, incoming...)
+			inserted = true
+		}
+		merged = append(merged, ch)
+	}
+	if !inserted {
+		merged = append(merged, incoming...)
+	}
+
+	p.data = canonicalOrder(merged)
+	return nil
+}
+
+// singleInstance reports whether the spec allows at most one chunk of the type
+// in a file.
+func singleInstance(ct chunkType) bool {
+	switch ct {
+	case ChunkTypeChromaticity, ChunkTypeGamma, ChunkTypeICC, ChunkTypeSigBits,
+		ChunkTypeRGB, ChunkTypeBkgdColor, ChunkTypeHistogram,
+		ChunkTypeTransparency, ChunkTypePxSize, ChunkTypeStereo, ChunkTypeExif,
+		ChunkTypeTimeChanged:
+		return true
+	default:
+		return false
+	}
+}
+
+// MergePNGs writes a PNG to dest made of the critical chunks of imageSource
+// (its header, palette, and image data) and the ancillary chunks of
+// metaSource. The ancillary chunks of imageSource and chunks of an unknown type
+// are left out, and the metadata is sorted into the positions the spec
+// requires. The two images must have the same dimensions, bit depth, and color
+// type, since chunks such as pHYs, tRNS, bKGD, and sBIT are only meaningful for
+// matching images. For indexed color images whose palettes differ, the tRNS,
+// bKGD, and hIST chunks of metaSource are left out too, since they refer to
+// palette entries.
+func MergePNGs(imageSource *Parser, metaSource *Parser, dest io.Writer) error {
+	if !imageSource.parsed {
+		return errors.New("unable to merge PNGs: image source not parsed")
+	}
+	if !metaSource.parsed {
+		return errors.New("unable to merge PNGs: metadata source not parsed")
+	}
+
+	img, err := imageSource.Header()
+	if err != nil {
+		return fmt.Errorf("unable to read image source header: %v", err)
+	}
+	meta, err := metaSource.Header()
+	if err != nil {
+		return fmt.Errorf("unable to read metadata source header: %v", err)
+	}
+	if img.Width != meta.Width || img.Height != meta.Height {
+		return fmt.Errorf("unable to merge %dx%d image with metadata of "+
+			"%dx%d image", img.Width, img.Height, meta.Width, meta.Height)
+	}
+	if img.ColorType != meta.ColorType {
+		return fmt.Errorf("unable to merge color type %d image with metadata of "+
+			"color type %d image", img.ColorType, meta.ColorType)
+	}
+	if img.BitDepth != meta.BitDepth {
+		return fmt.Errorf("unable to merge %d bit image with metadata of %d bit "+
+			"image", img.BitDepth, meta.BitDepth)
+	}
+
+	samePalette := img.ColorType != 3 ||
+		bytes.Equal(paletteData(imageSource), paletteData(metaSource))
+
+	var chunks []Chunk
+	for _, ch := range imageSource.data {
+		if !ch.IsCritical() {
+			continue
+		}
+		if ch.Type == ChunkTypeEnd {
+			for _, m := range metaSource.data {
+				if !m.IsAncillary() || m.IsUnknown() {
+					continue
+				}
+				if !samePalette && refersToPalette(m) {
+					continue
+				}
+				chunks = append(chunks, m)
+			}
+		}
+		chunks = append(chunks, ch)
+	}
+
+	_, err = writeChunks(dest, canonicalOrder(chunks))
+	return err
+}
+
+// paletteData gives the data of the first PLTE chunk of p, or nil if it has
+// none.
+func paletteData(p *Parser) []byte {
+	for _, ch := range p.data {
+		if ch.Type == ChunkTypePalette {
+			return ch.Data
+		}
+	}
+
+	return nil
+}
+
+// refersToPalette reports whether the chunk holds palette indexes or one entry
+// per palette color, as tRNS, bKGD, and hIST do in indexed color images.
+func refersToPalette(ch Chunk) bool {
+	switch ch.Type {
+	case ChunkTypeTransparency, ChunkTypeBkgdColor, ChunkTypeHistogram:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,56 @@
+package png
+
+// NormalizationProfile selects the cleanup operations Normalize applies to
+// make files for the same image come out the same.
+type NormalizationProfile struct {
+	// StripTime drops tIME chunks, which record when the file was written
+	StripTime bool
+	// StripSignatures drops dSIG chunks, which no longer match once the file
+	// has been changed
+	StripSignatures bool
+	// StripPrivate drops chunks of private types, which hold application
+	// specific data
+	StripPrivate bool
+	// KeepKeywords lists the keywords of the text chunks to keep, dropping all
+	// others. Text chunks are left alone when it is nil.
+	KeepKeywords []string
+	// Canonical sorts the remaining chunks into the order the spec recommends
+	Canonical bool
+}
+
+// DefaultNormalization is the profile applied by the -normalize flag. Color
+// space chunks (iCCP, sRGB, cHRM, and gAMA) are always kept.
+var DefaultNormalization = NormalizationProfile{
+	StripTime:       true,
+	StripSignatures: true,
+	StripPrivate:    true,
+	KeepKeywords:    []string{"Author", "Copyright", "Description", "Comment"},
+	Canonical:       true,
+}
+
+// Normalize applies the operations selected by profile to the parsed chunks.
+// Text chunks whose keyword cannot be read are dropped when keywords are
+// filtered.
+func (p *Parser) Normalize(profile NormalizationProfile) error {
+	keepText := FilterTextKeywords(profile.KeepKeywords...)
+	err := p.TransformChunks(func(ch Chunk) (Chunk, bool, error) {
+		switch {
+		case profile.StripTime && ch.Type == ChunkTypeTimeChanged,
+			profile.StripSignatures && ch.Type == ChunkTypeDigiSignal,
+			profile.StripPrivate && ch.IsPrivate():
+			return ch, false, nil
+		case profile.KeepKeywords != nil && ch.IsText():
+			return ch, keepText(ch), nil
+		default:
+			return ch, true, nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if profile.Canonical {
+		p.Canonicalize()
+	}
+	return nil
+}
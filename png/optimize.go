@@ -0,0 +1,141 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+)
+
+// FilterStrategy selects how OptimizeFilters picks the filter for each
+// scanline.
+type FilterStrategy int
+
+const (
+	// FilterStrategyNone leaves every scanline unfiltered, which suits
+	// indexed color and low bit depth images best
+	FilterStrategyNone FilterStrategy = iota
+	// FilterStrategyMinimumSum tries every filter on each scanline and keeps
+	// the one whose output bytes, taken as signed values, have the smallest
+	// sum of absolute values. This is the heuristic libpng uses.
+	FilterStrategyMinimumSum
+	// FilterStrategyPaeth applies the Paeth filter to every scanline
+	FilterStrategyPaeth
+)
+
+// OptimizeFilters returns a copy of the parser whose image data has had its
+// scanline filters chosen again by strategy and been compressed at the best
+// zlib level. Other chunks are copied unchanged. The pixels are not changed,
+// only how they are encoded, and the result is not guaranteed to be smaller.
+func (p *Parser) OptimizeFilters(strategy FilterStrategy) (*Parser, error) {
+	if strategy < FilterStrategyNone || strategy > FilterStrategyPaeth {
+		return nil, fmt.Errorf("unknown filter strategy %d", strategy)
+	}
+
+	d, err := p.newDecoder()
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := p.DecompressedIDATReader()
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	passes := []pass{{0, 0, 1, 1}}
+	if d.hdr.InterlaceMethod == 1 {
+		passes = adam7Passes
+	}
+
+	var raw bytes.Buffer
+	bpp := d.bpp()
+	for _, ps := range passes {
+		err := d.readPass(zr, ps, func(row, cols int, line, prev []byte) error {
+			ft, filtered := chooseFilter(strategy, line, prev, bpp)
+			raw.WriteByte(ft)
+			raw.Write(filtered)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var compressed bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compress image data: %v", err)
+	}
+	if _, err := zw.Write(raw.Bytes()); err != nil {
+		return nil, fmt.Errorf("unable to compress image data: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to compress image data: %v", err)
+	}
+
+	size := p.idatChunkSize
+	if size <= 0 {
+		size = defaultIDATChunkSize
+	}
+
+	cp := p.Copy()
+	cp.data = replaceData(cp.data, compressed.Bytes(), size)
+	return cp, nil
+}
+
+// chooseFilter filters an unfiltered scanline as strategy directs, returning
+// the filter type used and the filtered bytes.
+func chooseFilter(strategy FilterStrategy, line, prev []byte,
+	bpp int) (byte, []byte) {
+	switch strategy {
+	case FilterStrategyNone:
+		return filterNone, append([]byte(nil), line...)
+	case FilterStrategyPaeth:
+		return filterPaeth, filterLine(filterPaeth, line, prev, bpp)
+	}
+
+	var best []byte
+	var bestType byte
+	bestSum := -1
+	for ft := byte(filterNone); ft <= filterPaeth; ft++ {
+		filtered := filterLine(ft, line, prev, bpp)
+
+		var sum int
+		for _, b := range filtered {
+			sum += abs(int(int8(b)))
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestType, bestSum = filtered, ft, sum
+		}
+	}
+
+	return bestType, best
+}
+
+// filterLine applies filter ft to an unfiltered scanline, the inverse of
+// unfilter. prev is the previous unfiltered scanline of the pass.
+func filterLine(ft byte, line, prev []byte, bpp int) []byte {
+	out := make([]byte, len(line))
+	for i, b := range line {
+		var left, upLeft byte
+		if i >= bpp {
+			left, upLeft = line[i-bpp], prev[i-bpp]
+		}
+		up := prev[i]
+
+		switch ft {
+		case filterNone:
+			out[i] = b
+		case filterSub:
+			out[i] = b - left
+		case filterUp:
+			out[i] = b - up
+		case filterAverage:
+			out[i] = b - byte((int(left)+int(up))/2)
+		case filterPaeth:
+			out[i] = b - paeth(left, up, upLeft)
+		}
+	}
+
+	return out
+}
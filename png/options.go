@@ -0,0 +1,131 @@
+package png
+
+// Option customizes how a Parser reads its input. Options are handed to New
+// after the required arguments.
+type Option func(*Parser)
+
+const (
+	defaultBufferSize       = 4096
+	defaultProgressInterval = 10
+)
+
+// DefaultOptions returns the options New applies before any given by the
+// caller: a 4096 byte read buffer, no chunk size limit, no CRC verification,
+// no ordering checks, progress reported every 10 chunks, and every pixel
+// sampled for image statistics.
+func DefaultOptions() []Option {
+	return []Option{
+		WithBufferSize(defaultBufferSize),
+		WithMaxChunkSize(0),
+		WithCRCVerification(false),
+		WithStrictOrdering(false),
+		WithProgressInterval(defaultProgressInterval),
+		WithSampleRate(1),
+	}
+}
+
+// WithBufferSize sets the size of the buffered reader wrapped around the
+// input. Larger buffers, such as 64KB or 1MB, cut the number of reads made
+// from the underlying input when parsing large files from disk, at the cost
+// of holding that much memory per parser. The default of 4096 bytes suits
+// small images and many parsers open at once.
+func WithBufferSize(n int) Option {
+	return func(p *Parser) {
+		p.bufSize = n
+	}
+}
+
+// WithMaxChunkSize limits the data length of any single chunk. Parsing fails
+// on a chunk declaring more than n bytes of data. A limit of 0 disables the
+// check.
+func WithMaxChunkSize(n int64) Option {
+	return func(p *Parser) {
+		p.maxChunkSize = n
+	}
+}
+
+// WithCRCVerification makes parsing fail on any chunk whose stored CRC does not
+// match its type and data.
+func WithCRCVerification(enabled bool) Option {
+	return func(p *Parser) {
+		p.verifyCRC = enabled
+	}
+}
+
+// WithStrictOrdering makes parsing fail when chunks break the ordering rules
+// of the spec: IHDR must come first, PLTE must precede IDAT, IDAT chunks must
+// be consecutive, and nothing may follow IEND.
+func WithStrictOrdering(enabled bool) Option {
+	return func(p *Parser) {
+		p.strictOrdering = enabled
+	}
+}
+
+// WithProgressCallback has parsing report its progress to fn, handing it the
+// number of chunks read so far and the number of bytes of input they span,
+// signature included. It is called every few chunks as set by
+// WithProgressInterval. The callback runs on the goroutine doing the parsing,
+// which waits for it to return, so it must not block.
+func WithProgressCallback(fn func(chunksRead int, bytesRead int64)) Option {
+	return func(p *Parser) {
+		p.progress = fn
+	}
+}
+
+// WithProgressInterval sets how many chunks are read between calls to the
+// progress callback. An interval of 0 or less selects the default of 10.
+func WithProgressInterval(n int) Option {
+	return func(p *Parser) {
+		if n <= 0 {
+			n = defaultProgressInterval
+		}
+		p.progressInterval = n
+	}
+}
+
+// Recompress makes output methods such as StripTags decompress the image data
+// and compress it again at the given zlib level, from zlib.NoCompression to
+// zlib.BestCompression. The IDAT chunks are rewritten in pieces of the size
+// set by WithIDATChunkSize, or 32768 bytes by default.
+func Recompress(level int) Option {
+	return func(p *Parser) {
+		p.recompress = true
+		p.compressLevel = level
+	}
+}
+
+// WithIDATChunkSize makes output methods such as WriteTo concatenate the image
+// data and split it into IDAT chunks of at most n bytes each. A size of 0 or
+// less selects the default of 32768 bytes, matching libpng. Without this
+// option the IDAT chunks are written as they were parsed.
+func WithIDATChunkSize(n int) Option {
+	return func(p *Parser) {
+		if n <= 0 {
+			n = defaultIDATChunkSize
+		}
+		p.idatChunkSize = n
+	}
+}
+
+// WithCanonicalOrder makes output methods such as WriteTo sort ancillary chunks
+// into the positions the spec requires relative to PLTE and IDAT, as
+// Canonicalize does, without changing the parsed chunks. This keeps the output
+// valid after chunks have been inserted or merged in at the end.
+func WithCanonicalOrder(enabled bool) Option {
+	return func(p *Parser) {
+		p.canonical = enabled
+	}
+}
+
+// WithSampleRate makes ImageStatistics look at only every nth pixel, in the
+// order pixels are stored, giving approximate statistics for large images in a
+// fraction of the time. The image data is still decompressed in full. A rate of
+// 1 or less samples every pixel.
+func WithSampleRate(n int) Option {
+	return func(p *Parser) {
+		if n < 1 {
+			n = 1
+		}
+		p.sampleRate = n
+	}
+}
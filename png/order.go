@@ -0,0 +1,86 @@
+package png
+
+import "sort"
+
+// Positions a chunk may take relative to the critical chunks
+const (
+	rankHeader = iota
+	rankBeforePalette
+	rankPalette
+	rankBeforeData
+	rankData
+	rankAfterData
+	rankEnd
+)
+
+// chunkRank gives the position the spec requires for a chunk type, or -1 if
+// the type may appear anywhere between IHDR and IEND.
+func chunkRank(ct chunkType) int {
+	switch ct {
+	case ChunkTypeHeader:
+		return rankHeader
+	case ChunkTypeChromaticity, ChunkTypeGamma, ChunkTypeICC, ChunkTypeSigBits,
+		ChunkTypeRGB:
+		return rankBeforePalette
+	case ChunkTypePalette:
+		return rankPalette
+	case ChunkTypeBkgdColor, ChunkTypeHistogram, ChunkTypeTransparency,
+		ChunkTypePxSize, ChunkTypeSugPalette, ChunkTypeStereo, ChunkTypeExif:
+		return rankBeforeData
+	case ChunkTypeData:
+		return rankData
+	case ChunkTypeTimeChanged, ChunkTypeTxtISO8859, ChunkTypeTxtUTF8,
+		ChunkTypeTxtCompressed:
+		return rankAfterData
+	case ChunkTypeEnd:
+		return rankEnd
+	default:
+		return -1
+	}
+}
+
+// canonicalOrder sorts chunks into the order the spec requires: chunks that
+// must precede PLTE, then PLTE, then chunks that must precede IDAT, then IDAT,
+// then chunks that are free to follow the image data. Chunks that may appear
+// anywhere keep their position relative to the critical chunks, and chunks
+// sharing a position keep their relative order.
+func canonicalOrder(chunks []Chunk) []Chunk {
+	type ranked struct {
+		rank int
+		ch   Chunk
+	}
+
+	sorted := make([]ranked, len(chunks))
+	current := rankBeforePalette
+	for i, ch := range chunks {
+		r := chunkRank(ch.Type)
+		switch r {
+		case rankHeader:
+			current = rankBeforePalette
+		case rankPalette:
+			current = rankBeforeData
+		case rankData:
+			current = rankAfterData
+		case -1:
+			r = current
+		}
+		sorted[i] = ranked{r, ch}
+	}
+
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].rank < sorted[b].rank
+	})
+
+	out := make([]Chunk, len(sorted))
+	for i, r := range sorted {
+		out[i] = r.ch
+	}
+
+	return out
+}
+
+// Canonicalize reorders the parsed chunks so that each sits where the spec
+// requires. See canonicalOrder for the ordering applied.
+func (p *Parser) Canonicalize() {
+	p.data = canonicalOrder(p.data)
+}
@@ -5,36 +5,44 @@ package png
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"image/color"
 	"io"
 	"os"
+	"strings"
 )
 
+// PNGSignature holds the magic bytes that begin every PNG file
+var PNGSignature = [8]byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a}
+
 var (
-	pngHeader = []byte("\x89\x50\x4e\x47\x0d\x0a\x1a\x0a")
-	ctHdr     = []byte{'I', 'H', 'D', 'R'}
-	ctPlte    = []byte{'P', 'L', 'T', 'E'}
-	ctDat     = []byte{'I', 'D', 'A', 'T'}
-	ctEnd     = []byte{'I', 'E', 'N', 'D'}
-	ctBkgd    = []byte{'b', 'K', 'G', 'D'}
-	ctChrm    = []byte{'c', 'H', 'R', 'M'}
-	ctDSig    = []byte{'d', 'S', 'I', 'G'}
-	ctExif    = []byte{'e', 'X', 'I', 'f'}
-	ctGama    = []byte{'g', 'A', 'M', 'A'}
-	ctHist    = []byte{'h', 'I', 'S', 'T'}
-	ctIccp    = []byte{'i', 'C', 'C', 'P'}
-	ctItxt    = []byte{'i', 'T', 'X', 't'}
-	ctPhys    = []byte{'p', 'H', 'Y', 's'}
-	ctSbit    = []byte{'s', 'B', 'I', 'T'}
-	ctSplt    = []byte{'s', 'P', 'L', 'T'}
-	ctSrgb    = []byte{'s', 'R', 'G', 'B'}
-	ctSter    = []byte{'s', 'T', 'E', 'R'}
-	ctText    = []byte{'t', 'E', 'X', 't'}
-	ctTime    = []byte{'t', 'I', 'M', 'E'}
-	ctTrns    = []byte{'t', 'R', 'N', 'S'}
-	ctZtxt    = []byte{'z', 'T', 'X', 't'}
+	ctHdr  = []byte{'I', 'H', 'D', 'R'}
+	ctPlte = []byte{'P', 'L', 'T', 'E'}
+	ctDat  = []byte{'I', 'D', 'A', 'T'}
+	ctEnd  = []byte{'I', 'E', 'N', 'D'}
+	ctBkgd = []byte{'b', 'K', 'G', 'D'}
+	ctChrm = []byte{'c', 'H', 'R', 'M'}
+	ctDSig = []byte{'d', 'S', 'I', 'G'}
+	ctExif = []byte{'e', 'X', 'I', 'f'}
+	ctGama = []byte{'g', 'A', 'M', 'A'}
+	ctHist = []byte{'h', 'I', 'S', 'T'}
+	ctIccp = []byte{'i', 'C', 'C', 'P'}
+	ctItxt = []byte{'i', 'T', 'X', 't'}
+	ctPhys = []byte{'p', 'H', 'Y', 's'}
+	ctSbit = []byte{'s', 'B', 'I', 'T'}
+	ctSplt = []byte{'s', 'P', 'L', 'T'}
+	ctSrgb = []byte{'s', 'R', 'G', 'B'}
+	ctSter = []byte{'s', 'T', 'E', 'R'}
+	ctText = []byte{'t', 'E', 'X', 't'}
+	ctTime = []byte{'t', 'I', 'M', 'E'}
+	ctTrns = []byte{'t', 'R', 'N', 'S'}
+	ctZtxt = []byte{'z', 'T', 'X', 't'}
 )
 
 type chunkType uint32
@@ -121,12 +129,95 @@ func (ct chunkType) String() string {
 	}
 }
 
+// chunkTypeNames holds the name of the constant for each chunk type
+var chunkTypeNames = [...]string{
+	ChunkTypeUnknown:       "ChunkTypeUnknown",
+	ChunkTypeHeader:        "ChunkTypeHeader",
+	ChunkTypePalette:       "ChunkTypePalette",
+	ChunkTypeData:          "ChunkTypeData",
+	ChunkTypeEnd:           "ChunkTypeEnd",
+	ChunkTypeBkgdColor:     "ChunkTypeBkgdColor",
+	ChunkTypeChromaticity:  "ChunkTypeChromaticity",
+	ChunkTypeDigiSignal:    "ChunkTypeDigiSignal",
+	ChunkTypeExif:          "ChunkTypeExif",
+	ChunkTypeGamma:         "ChunkTypeGamma",
+	ChunkTypeHistogram:     "ChunkTypeHistogram",
+	ChunkTypeICC:           "ChunkTypeICC",
+	ChunkTypeTxtUTF8:       "ChunkTypeTxtUTF8",
+	ChunkTypePxSize:        "ChunkTypePxSize",
+	ChunkTypeSigBits:       "ChunkTypeSigBits",
+	ChunkTypeSugPalette:    "ChunkTypeSugPalette",
+	ChunkTypeRGB:           "ChunkTypeRGB",
+	ChunkTypeStereo:        "ChunkTypeStereo",
+	ChunkTypeTxtISO8859:    "ChunkTypeTxtISO8859",
+	ChunkTypeTimeChanged:   "ChunkTypeTimeChanged",
+	ChunkTypeTransparency:  "ChunkTypeTransparency",
+	ChunkTypeTxtCompressed: "ChunkTypeTxtCompressed",
+}
+
+// GoString gives the Go syntax for a chunk type, e.g. "png.ChunkTypeHeader"
+func (ct chunkType) GoString() string {
+	if int(ct) >= len(chunkTypeNames) {
+		return fmt.Sprintf("png.chunkType(%d)", uint32(ct))
+	}
+
+	return "png." + chunkTypeNames[ct]
+}
+
+// Category gives "Critical" for the chunk types a decoder must understand to
+// display the image (IHDR, PLTE, IDAT, and IEND) and "Ancillary" for the rest.
+func (ct chunkType) Category() string {
+	switch ct {
+	case ChunkTypeHeader, ChunkTypePalette, ChunkTypeData, ChunkTypeEnd:
+		return "Critical"
+	default:
+		return "Ancillary"
+	}
+}
+
+// Abbreviation gives the four character code of the chunk type, e.g. "IHDR",
+// without the description String adds. It is empty for ChunkTypeUnknown; use
+// Chunk.RawType to get the code of an unknown chunk.
+func (ct chunkType) Abbreviation() string {
+	return string(typeBytes(ct))
+}
+
+// ChunkTypes lists every chunk type the package knows about, critical types
+// first.
+func ChunkTypes() []chunkType {
+	var types []chunkType
+	for ct := ChunkTypeHeader; int(ct) < len(chunkTypeNames); ct++ {
+		types = append(types, ct)
+	}
+
+	return types
+}
+
 // Parser knows how to parse and operate on PNG files
 type Parser struct {
 	Path string
 	rc   io.ReadCloser
 	br   *bufio.Reader
 	data []Chunk
+
+	parsed bool
+	// fileSize is the size of the input file as found when parsing began, or 0
+	// if the input is not a regular file
+	fileSize int64
+
+	bufSize        int
+	maxChunkSize   int64
+	verifyCRC      bool
+	strictOrdering bool
+
+	progress         func(chunksRead int, bytesRead int64)
+	progressInterval int
+
+	recompress    bool
+	compressLevel int
+	idatChunkSize int
+	canonical     bool
+	sampleRate    int
 }
 
 // Chunk holds information and data in an image.
@@ -135,6 +226,207 @@ type Chunk struct {
 	CRC    [4]byte
 	Type   chunkType
 	Data   []byte
+
+	// Offset is the position of the chunk's length field in the parsed file.
+	// It is zero for chunks that were not read from a file.
+	Offset int64
+
+	// rawType holds the type bytes as read from the file, which are the only
+	// record of the type of unknown chunks
+	rawType [4]byte
+}
+
+// maxChunkLength is the largest data length the spec allows for a chunk
+const maxChunkLength = 1<<31 - 1
+
+// NewChunk builds a chunk of the given type holding data, computing its length
+// and CRC.
+func NewChunk(ct chunkType, data []byte) (Chunk, error) {
+	ch := Chunk{Type: ct}
+	if ct == ChunkTypeUnknown {
+		return ch, errors.New("unable to build chunk of unknown type")
+	}
+	if int64(len(data)) > maxChunkLength {
+		return ch, fmt.Errorf("chunk data is %d bytes, limit is %d",
+			len(data), maxChunkLength)
+	}
+
+	ch.Data = make([]byte, len(data))
+	copy(ch.Data, data)
+	ch.reseal()
+	return ch, nil
+}
+
+// NewRawChunk builds a chunk identified by its four type bytes holding data,
+// computing its length and CRC. Unlike NewChunk it accepts types this package
+// does not know, such as private chunks, which must still be four ASCII
+// letters.
+func NewRawChunk(fourcc []byte, data []byte) (Chunk, error) {
+	if len(fourcc) != 4 {
+		return Chunk{}, fmt.Errorf("chunk type %q is not 4 characters", fourcc)
+	}
+	for _, c := range fourcc {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			return Chunk{}, fmt.Errorf("chunk type %q is not 4 letters", fourcc)
+		}
+	}
+	if int64(len(data)) > maxChunkLength {
+		return Chunk{}, fmt.Errorf("chunk data is %d bytes, limit is %d",
+			len(data), maxChunkLength)
+	}
+
+	ch := Chunk{Type: getChunkType(fourcc)}
+	copy(ch.rawType[:], fourcc)
+	ch.Data = make([]byte, len(data))
+	copy(ch.Data, data)
+	ch.reseal()
+	return ch, nil
+}
+
+// Clone returns a deep copy of the chunk so that changes to its Data do not
+// affect the original.
+func (ch Chunk) Clone() Chunk {
+	c := ch
+	if ch.Data != nil {
+		c.Data = make([]byte, len(ch.Data))
+		copy(c.Data, ch.Data)
+	}
+
+	return c
+}
+
+// reseal recomputes the length and CRC fields from the chunk's type and data.
+func (ch *Chunk) reseal() {
+	binary.BigEndian.PutUint32(ch.Length[:], uint32(len(ch.Data)))
+	ch.CRC = computeCRC(ch.typeBytes(), ch.Data)
+}
+
+// typeBytes gives the four type bytes of the chunk, falling back on those read
+// from the file for unknown types. It returns nil for unknown chunks that were
+// not read from a file.
+func (ch Chunk) typeBytes() []byte {
+	if ch.Type != ChunkTypeUnknown {
+		return typeBytes(ch.Type)
+	}
+	if ch.rawType == [4]byte{} {
+		return nil
+	}
+
+	return ch.rawType[:]
+}
+
+// IsUnknown reports whether the chunk's type is not one this package
+// recognizes, such as a private or vendor-specific chunk.
+func (ch Chunk) IsUnknown() bool {
+	return ch.Type == ChunkTypeUnknown
+}
+
+// RawType gives the four character type code of the chunk as it appears in
+// the file, which identifies unknown chunks. Bytes that are not printable
+// ASCII are written as \xNN escapes.
+func (ch Chunk) RawType() string {
+	var sb strings.Builder
+	for _, b := range ch.typeBytes() {
+		if b >= 0x20 && b <= 0x7e {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "\\x%02x", b)
+		}
+	}
+
+	return sb.String()
+}
+
+// Hexdump writes the raw bytes of the chunk (length, type, data, and CRC) to w
+// in the same layout as `hexdump -C`.
+func (ch Chunk) Hexdump(w io.Writer) error {
+	t := ch.typeBytes()
+	if t == nil {
+		return errors.New("type bytes of unknown chunk not known")
+	}
+
+	d := hex.Dumper(w)
+	for _, b := range [][]byte{ch.Length[:], t, ch.Data, ch.CRC[:]} {
+		if _, err := d.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return d.Close()
+}
+
+// IsCritical reports whether the chunk is one of the types a decoder must
+// understand to display the image: IHDR, PLTE, IDAT, and IEND.
+func (ch Chunk) IsCritical() bool {
+	return ch.Type.Category() == "Critical"
+}
+
+// IsAncillary reports whether the chunk carries optional information that is
+// not needed to display the image.
+func (ch Chunk) IsAncillary() bool {
+	return !ch.IsCritical()
+}
+
+// SafeToStrip reports whether the chunk should be dropped when an editor that
+// does not understand it modifies the image. This is the case when the
+// safe-to-copy bit (bit 5 of the fourth type byte) is clear, meaning the chunk
+// depends on the image data it came with. Chunks whose type bytes are not known
+// are always safe to strip.
+func (ch Chunk) SafeToStrip() bool {
+	t := ch.typeBytes()
+	return t == nil || t[3]&0x20 == 0
+}
+
+// IsPrivate reports whether the chunk is of a private type, defined by an
+// application rather than the spec. This is the case when the private bit (bit
+// 5 of the second type byte) is set. Chunks whose type bytes are not known are
+// not private.
+func (ch Chunk) IsPrivate() bool {
+	t := ch.typeBytes()
+	return t != nil && t[1]&0x20 != 0
+}
+
+// IsText reports whether the chunk holds text: tEXt, zTXt, or iTXt.
+func (ch Chunk) IsText() bool {
+	switch ch.Type {
+	case ChunkTypeTxtISO8859, ChunkTypeTxtCompressed, ChunkTypeTxtUTF8:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsColorSpace reports whether the chunk describes how to interpret the
+// image's colors: cHRM, gAMA, sRGB, or iCCP.
+func (ch Chunk) IsColorSpace() bool {
+	switch ch.Type {
+	case ChunkTypeChromaticity, ChunkTypeGamma, ChunkTypeRGB, ChunkTypeICC:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTimestamp reports whether the chunk records a time, which is only true of
+// tIME.
+func (ch Chunk) IsTimestamp() bool {
+	return ch.Type == ChunkTypeTimeChanged
+}
+
+// Equals reports whether two chunks have the same type, length, CRC, and data.
+// Where the chunks came from is not compared, and nil data is equal to empty
+// data.
+func (ch Chunk) Equals(other Chunk) bool {
+	return ch.Type == other.Type &&
+		bytes.Equal(ch.typeBytes(), other.typeBytes()) &&
+		ch.Length == other.Length &&
+		ch.CRC == other.CRC &&
+		bytes.Equal(ch.Data, other.Data)
+}
+
+// DataHash returns the SHA-256 digest of the chunk's data.
+func (ch Chunk) DataHash() [32]byte {
+	return sha256.Sum256(ch.Data)
 }
 
 // headerChunk gives us a more specific breakdown of the IHDR chunk since it
@@ -151,13 +443,92 @@ type headerChunk struct {
 	InterlaceMethod   byte
 }
 
-// New returns a new parser on the given input
-func New(imgName string, rc io.ReadCloser) *Parser {
-	return &Parser{
+// ColorType is the IHDR field describing how pixels are stored.
+type ColorType byte
+
+// The color types allowed by the spec
+const (
+	ColorTypeGrayscale      ColorType = 0
+	ColorTypeTruecolor      ColorType = 2
+	ColorTypeIndexed        ColorType = 3
+	ColorTypeGrayscaleAlpha ColorType = 4
+	ColorTypeTruecolorAlpha ColorType = 6
+)
+
+// InterlaceMethod is the IHDR field giving the order pixels are transmitted in.
+type InterlaceMethod byte
+
+// The interlace methods allowed by the spec
+const (
+	InterlaceNone  InterlaceMethod = 0
+	InterlaceAdam7 InterlaceMethod = 1
+)
+
+// NewHeaderChunk builds an IHDR chunk from its fields, checking them the same
+// way parsed headers are checked. The compression and filter methods must be 0,
+// the only methods the spec defines.
+func NewHeaderChunk(width, height uint32, bitDepth byte, colorType ColorType,
+	compressionMethod, filterMethod byte,
+	interlaceMethod InterlaceMethod) (Chunk, error) {
+	hdr := headerChunk{
+		Width:             width,
+		Height:            height,
+		BitDepth:          bitDepth,
+		ColorType:         byte(colorType),
+		CompressionMethod: compressionMethod,
+		FilterMethod:      filterMethod,
+		InterlaceMethod:   byte(interlaceMethod),
+	}
+	if err := validateHeaderChunk(hdr); err != nil {
+		return Chunk{}, err
+	}
+
+	data := make([]byte, 13)
+	binary.BigEndian.PutUint32(data[0:4], hdr.Width)
+	binary.BigEndian.PutUint32(data[4:8], hdr.Height)
+	data[8] = hdr.BitDepth
+	data[9] = hdr.ColorType
+	data[10] = hdr.CompressionMethod
+	data[11] = hdr.FilterMethod
+	data[12] = hdr.InterlaceMethod
+
+	return NewChunk(ChunkTypeHeader, data)
+}
+
+// New returns a new parser on the given input. Options are applied on top of
+// DefaultOptions.
+func New(imgName string, rc io.ReadCloser, opts ...Option) *Parser {
+	p := &Parser{
 		Path: imgName,
 		rc:   rc,
-		br:   bufio.NewReader(rc),
 	}
+
+	for _, opt := range DefaultOptions() {
+		opt(p)
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.br = bufio.NewReaderSize(rc, p.bufSize)
+	return p
+}
+
+// NewBuffered returns a new parser reading its input through a buffer of
+// bufSize bytes. It is shorthand for New with WithBufferSize.
+func NewBuffered(imgName string, rc io.ReadCloser, bufSize int) *Parser {
+	return New(imgName, rc, WithBufferSize(bufSize))
+}
+
+// Must is a helper that wraps a call returning (*Parser, error) and panics if
+// the error is non-nil. It is intended for use in initialization code and
+// tests working with trusted fixtures, not in production error paths.
+func Must(p *Parser, err error) *Parser {
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return p
 }
 
 // IsPNG checks for the required headers in the input. It does not advance the
@@ -171,7 +542,37 @@ func (p *Parser) IsPNG() (bool, error) {
 		return false, err
 	}
 
-	return bytes.Compare(pngHeader, b) == 0, nil
+	return IsPNGSignature(b), nil
+}
+
+// IsPNGSignature reports whether b begins with the PNG magic bytes.
+func IsPNGSignature(b []byte) bool {
+	if len(b) < len(PNGSignature) {
+		return false
+	}
+
+	return bytes.Compare(PNGSignature[:], b[:len(PNGSignature)]) == 0
+}
+
+// IsPNGFile checks whether the file at path begins with the PNG magic bytes.
+// Only the signature is read, so it is cheap to call before constructing a
+// Parser. Files too short to hold a signature are reported as non-PNG.
+func IsPNGFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	b := make([]byte, len(PNGSignature))
+	if _, err := io.ReadFull(f, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return IsPNGSignature(b), nil
 }
 
 // Parse reads the chunks from the input and makes them available via the
@@ -179,7 +580,32 @@ func (p *Parser) IsPNG() (bool, error) {
 // reader operations won't work or the reader must be reset *after* calling
 // this.
 func (p *Parser) Parse() error {
-	chunks, err := p.chunks()
+	return p.ParseContext(context.Background())
+}
+
+// ParseContext is like Parse but stops early when ctx is done, returning the
+// context's error. Cancellation is checked after each complete chunk is read,
+// so it takes effect within the time needed to read one chunk: immediately for
+// typical files made of small chunks, but only after the current chunk for
+// files holding very large IDAT chunks.
+func (p *Parser) ParseContext(ctx context.Context) error {
+	chunks, err := p.chunks(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	p.data = chunks
+	p.parsed = true
+	return nil
+}
+
+// ParseHeadersOnly reads the length and type of each chunk from the input,
+// discarding chunk data instead of holding it in memory. This is enough to
+// check which chunks a file has without loading the image data. The Data of
+// the resulting chunks is nil and CRCs are not verified, so the parser cannot
+// be used to write output or to decode chunk contents.
+func (p *Parser) ParseHeadersOnly() error {
+	chunks, err := p.chunks(context.Background(), true)
 	if err != nil {
 		return err
 	}
@@ -199,11 +625,244 @@ func (p *Parser) WalkChunks(fn func(ch Chunk) bool) {
 	}
 }
 
+// TransformChunks hands each parsed chunk to fn, which returns the chunk to
+// store in its place, whether to keep it at all, and an error to abort the
+// walk. When a chunk's type or data is changed, its length and CRC are
+// recomputed. If fn returns an error the parsed chunks are left untouched.
+func (p *Parser) TransformChunks(fn func(ch Chunk) (Chunk, bool, error)) error {
+	var chunks []Chunk
+	for _, ch := range p.data {
+		next, keep, err := fn(ch.Clone())
+		if err != nil {
+			return err
+		}
+		if !keep {
+			continue
+		}
+
+		if next.Type != ch.Type || !bytes.Equal(next.Data, ch.Data) {
+			next.reseal()
+		}
+		chunks = append(chunks, next)
+	}
+
+	p.data = chunks
+	return nil
+}
+
+// InsertChunk adds a copy of ch to the parsed chunks just before IEND, or at
+// the end if there is no IEND chunk.
+func (p *Parser) InsertChunk(ch Chunk) error {
+	if !p.parsed {
+		return errors.New("unable to insert chunk: input not parsed")
+	}
+	if ch.IsCritical() {
+		return fmt.Errorf("unable to insert critical %s chunk", ch.Type)
+	}
+
+	i := len(p.data)
+	for j, c := range p.data {
+		if c.Type == ChunkTypeEnd {
+			i = j
+			break
+		}
+	}
+
+	p.data = append(p.data, Chunk{})
+	copy(p.data[i+1:], p.data[i:])
+	p.data[i] = ch.Clone()
+	return nil
+}
+
+// CorrectCRCs recomputes the CRC of every parsed chunk and replaces any stored
+// CRC that does not match, returning the number of chunks corrected. Use
+// WriteTo afterwards to emit the repaired file. The length and data fields are
+// trusted as they are, so corruption there cannot be fixed this way. Chunks of
+// an unknown type that were not read from a file are skipped since their type
+// bytes are not known.
+func (p *Parser) CorrectCRCs() int {
+	var corrected int
+	for i, ch := range p.data {
+		t := ch.typeBytes()
+		if t == nil {
+			continue
+		}
+
+		if crc := computeCRC(t, ch.Data); crc != ch.CRC {
+			p.data[i].CRC = crc
+			corrected++
+		}
+	}
+
+	return corrected
+}
+
+// Chunks returns copies of all chunks parsed from the file in the order they
+// appear. Modifying the returned chunks does not affect the parser.
+func (p *Parser) Chunks() []Chunk {
+	var chunks []Chunk
+	for _, ch := range p.data {
+		chunks = append(chunks, ch.Clone())
+	}
+
+	return chunks
+}
+
+// GetChunksByType returns copies of all chunks of the given type in the order
+// they appear in the file.
+func (p *Parser) GetChunksByType(ct chunkType) []Chunk {
+	var chunks []Chunk
+	for _, ch := range p.data {
+		if ch.Type == ct {
+			chunks = append(chunks, ch.Clone())
+		}
+	}
+
+	return chunks
+}
+
+// hasChunk reports whether any chunk of the given type was parsed, without
+// copying chunk data as GetChunksByType does.
+func (p *Parser) hasChunk(ct chunkType) bool {
+	for _, ch := range p.data {
+		if ch.Type == ct {
+			return true
+		}
+	}
+
+	return false
+}
+
+// firstChunk gives the first parsed chunk of the given type without copying
+// its data, so the caller must not modify it.
+func (p *Parser) firstChunk(ct chunkType) (Chunk, bool) {
+	for _, ch := range p.data {
+		if ch.Type == ct {
+			return ch, true
+		}
+	}
+
+	return Chunk{}, false
+}
+
+// ChunkHashes returns the SHA-256 digest of the data of the first chunk of
+// each type, which is enough to tell when content such as an ICC profile or
+// EXIF block changes between versions of a file. Unknown chunks all share
+// ChunkTypeUnknown, so only the first of them is hashed.
+func (p *Parser) ChunkHashes() map[chunkType][32]byte {
+	hashes := make(map[chunkType][32]byte)
+	for _, ch := range p.data {
+		if _, ok := hashes[ch.Type]; !ok {
+			hashes[ch.Type] = ch.DataHash()
+		}
+	}
+
+	return hashes
+}
+
+// IsIdentical reports whether both parsers hold equal chunks in the same order.
+func (p *Parser) IsIdentical(other *Parser) bool {
+	if len(p.data) != len(other.data) {
+		return false
+	}
+	for i, ch := range p.data {
+		if !ch.Equals(other.data[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Copy returns an independent parser holding deep copies of the parsed chunks.
+// The copy has no input of its own, so it cannot be parsed again and calling
+// Close on it is a no-op. Output methods such as StripTags and WriteTo only
+// read the parsed chunks and work the same on the copy.
+func (p *Parser) Copy() *Parser {
+	return &Parser{
+		Path:   p.Path,
+		data:   p.Chunks(),
+		parsed: p.parsed,
+
+		recompress:    p.recompress,
+		compressLevel: p.compressLevel,
+		idatChunkSize: p.idatChunkSize,
+		canonical:     p.canonical,
+		sampleRate:    p.sampleRate,
+	}
+}
+
+// SizeOnDisk gives the size of the file the parser reads from. It fails for
+// parsers that do not read from a regular file, such as those reading a pipe
+// or an in-memory buffer. The size is recorded when parsing, so it is still
+// known once the file has been closed.
+func (p *Parser) SizeOnDisk() (int64, error) {
+	if p.fileSize > 0 {
+		return p.fileSize, nil
+	}
+
+	return p.statFile()
+}
+
+// statFile gives the size of the file the parser reads from by asking the file
+// itself.
+func (p *Parser) statFile() (int64, error) {
+	f, ok := p.rc.(*os.File)
+	if !ok {
+		return 0, fmt.Errorf("%s is not backed by a file", p.Path)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("unable to stat %s: %v", p.Path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return 0, fmt.Errorf("%s is not a regular file", p.Path)
+	}
+
+	return info.Size(), nil
+}
+
 // Close closes the internal file
 func (p *Parser) Close() error {
+	if p.rc == nil {
+		return nil
+	}
+
 	return p.rc.Close()
 }
 
+// Header decodes the IHDR chunk of the parsed file.
+func (p *Parser) Header() (headerChunk, error) {
+	for _, ch := range p.data {
+		if ch.Type == ChunkTypeHeader {
+			return parseHeader(ch.Data)
+		}
+	}
+
+	return headerChunk{}, errors.New("no IHDR chunk")
+}
+
+// HasAlphaChannel reports whether the image carries transparency information,
+// either as an alpha channel (grayscale with alpha and truecolor with alpha) or
+// as a tRNS chunk for the other color types. Only IHDR and the presence of tRNS
+// are checked; the image data is not decompressed.
+func (p *Parser) HasAlphaChannel() (bool, error) {
+	hdr, err := p.Header()
+	if err != nil {
+		return false, err
+	}
+
+	switch hdr.ColorType {
+	case 4, 6:
+		return true, nil
+	case 0, 2, 3:
+		return p.hasChunk(ChunkTypeTransparency), nil
+	default:
+		return false, fmt.Errorf("invalid color type %d", hdr.ColorType)
+	}
+}
+
 // PrintHeader outputs header chunks to stdout
 func (p *Parser) PrintHeader() {
 	for _, ch := range p.data {
@@ -223,57 +882,22 @@ func (p *Parser) PrintHeader() {
 }
 
 // StripTags returns a version of the input file with all non-critical chunks
-// and metadata removed.
+// and metadata removed. Unknown chunks marked safe to copy are kept, since
+// they may carry data that applications need to handle the image and the spec
+// allows carrying them into a modified file.
 func (p *Parser) StripTags() io.Reader {
 	r, w := io.Pipe()
 
 	go func() {
-		if _, err := w.Write(pngHeader); err != nil {
-			w.CloseWithError(fmt.Errorf("unable to write PNG header: %v", err))
+		chunks, err := p.outputChunks(func(ch Chunk) bool {
+			return !FilterStrippable(ch)
+		})
+		if err != nil {
+			w.CloseWithError(err)
 			return
 		}
 
-		var passThrough = map[chunkType]bool{
-			ChunkTypeHeader:  true,
-			ChunkTypePalette: true,
-			ChunkTypeData:    true,
-			ChunkTypeEnd:     true,
-		}
-
-		var err error
-		p.WalkChunks(func(ch Chunk) bool {
-			if _, ok := passThrough[ch.Type]; ok {
-				var typeBytes []byte
-				switch ch.Type {
-				case ChunkTypeHeader:
-					typeBytes = ctHdr
-				case ChunkTypePalette:
-					typeBytes = ctPlte
-				case ChunkTypeData:
-					typeBytes = ctDat
-				case ChunkTypeEnd:
-					typeBytes = ctEnd
-				}
-				if _, e := w.Write(ch.Length[:]); e != nil {
-					err = fmt.Errorf("unable to write chunk length: %v", e)
-					return false
-				}
-				if _, e := w.Write(typeBytes); e != nil {
-					err = fmt.Errorf("unable to write chunk type: %v", e)
-					return false
-				}
-				if _, e := w.Write(ch.Data[:]); e != nil {
-					err = fmt.Errorf("unable to write chunk data: %v", e)
-					return false
-				}
-				if _, e := w.Write(ch.CRC[:]); e != nil {
-					err = fmt.Errorf("unable to write chunk CRC: %v", e)
-					return false
-				}
-			}
-			return true
-		})
-
+		_, err = writeChunks(w, chunks)
 		w.CloseWithError(err)
 	}()
 
@@ -281,9 +905,13 @@ func (p *Parser) StripTags() io.Reader {
 }
 
 // Chunks returns a slice of chunks parsed from the PNG
-func (p *Parser) chunks() ([]Chunk, error) {
+func (p *Parser) chunks(ctx context.Context, skipData bool) ([]Chunk, error) {
 	var chunks []Chunk
 
+	if size, err := p.statFile(); err == nil {
+		p.fileSize = size
+	}
+
 	b, err := p.IsPNG()
 	if err != nil {
 		return chunks, err
@@ -297,60 +925,166 @@ func (p *Parser) chunks() ([]Chunk, error) {
 		return chunks, fmt.Errorf("unable to read header: %v", err)
 	}
 
+	offset := int64(len(fileHdr))
+	var order chunkOrder
+	var foundEnd bool
 	for {
-		c := Chunk{}
+		c := Chunk{Offset: offset}
 
-		// Read LENGTH
-		read, err := io.ReadFull(p.br, c.Length[:])
-		if err == io.EOF {
+		// Read LENGTH. The input may only end here, between chunks.
+		err := readField(p.br, c.Length[:], "length", offset)
+		if te, ok := err.(*TruncationError); ok && te.Got == 0 {
 			break
 		}
 		if err != nil {
-			return chunks, fmt.Errorf("unable to read chunk length: %v", err)
+			return chunks, err
 		}
 
 		// Read TYPE
 		chType := make([]byte, 4)
-		read, err = io.ReadFull(p.br, chType)
-		if err == io.EOF {
-			break
-		}
+		err = readField(p.br, chType, "type", offset)
 		if err != nil {
-			return chunks, fmt.Errorf("unable to read chunk type: %v", err)
+			return chunks, err
 		}
 		c.Type = getChunkType(chType)
+		copy(c.rawType[:], chType)
+
+		if p.strictOrdering {
+			if err := checkOrder(order, c.Type); err != nil {
+				return chunks, err
+			}
+		}
 
 		// Read DATA
 		l := binary.BigEndian.Uint32(c.Length[:])
-		data := make([]byte, l)
-		read, err = io.ReadFull(p.br, data)
-		if err == io.EOF {
-			break
+		if l > maxChunkLength {
+			return chunks, &ParseError{
+				Kind: "ChunkTooLarge",
+				Message: fmt.Sprintf("%s chunk declares %d bytes, spec limit is %d",
+					chType, l, maxChunkLength),
+			}
+		}
+		if p.maxChunkSize > 0 && int64(l) > p.maxChunkSize {
+			return chunks, fmt.Errorf("%s chunk declares %d bytes, limit is %d",
+				chType, l, p.maxChunkSize)
+		}
+		if skipData {
+			err = skipField(p.br, int64(l), "data", offset)
+		} else {
+			c.Data = make([]byte, l)
+			err = readField(p.br, c.Data, "data", offset)
 		}
 		if err != nil {
-			return chunks, fmt.Errorf("unable to read chunk data: %v", err)
+			return chunks, err
 		}
-		c.Data = data
 
 		// Read CRC
-		read, err = io.ReadFull(p.br, c.CRC[:])
-		if err == io.EOF {
-			break
-		}
+		err = readField(p.br, c.CRC[:], "CRC", offset)
 		if err != nil {
-			return chunks, fmt.Errorf("unable to read chunk CRC: %v", err)
+			return chunks, err
 		}
-		if l := len(c.Length); read != l {
-			return chunks, fmt.Errorf(
-				"short read on chunk CRC (got %d bytes, expected %d)", read, l)
+		if p.verifyCRC && !skipData && computeCRC(chType, c.Data) != c.CRC {
+			return chunks, fmt.Errorf("CRC mismatch on %s chunk", chType)
+		}
+
+		if c.Type == ChunkTypeEnd {
+			foundEnd = true
 		}
 
 		chunks = append(chunks, c)
+		order.add(c.Type)
+		offset += int64(len(c.Length)+len(chType)+len(c.CRC)) + int64(l)
+
+		if p.progress != nil && len(chunks)%p.progressInterval == 0 {
+			p.progress(len(chunks), offset)
+		}
+
+		select {
+		case <-ctx.Done():
+			return chunks, ctx.Err()
+		default:
+		}
+	}
+
+	if !foundEnd {
+		return chunks, &ValidationError{
+			Kind:    "MissingIEND",
+			Message: "input ended without an IEND chunk",
+		}
 	}
 
 	return chunks, nil
 }
 
+// computeCRC calculates the CRC stored at the end of a chunk, which covers the
+// chunk type and data but not the length.
+func computeCRC(ct []byte, data []byte) [4]byte {
+	var crc [4]byte
+
+	h := crc32.NewIEEE()
+	h.Write(ct)
+	h.Write(data)
+	binary.BigEndian.PutUint32(crc[:], h.Sum32())
+
+	return crc
+}
+
+// chunkOrder tracks what checkOrder needs to know about the chunks read so
+// far, so that each chunk is checked without going back over the others.
+type chunkOrder struct {
+	read     int
+	last     chunkType
+	seenData bool
+}
+
+// add records that a chunk of type ct was read.
+func (o *chunkOrder) add(ct chunkType) {
+	o.read++
+	o.last = ct
+	if ct == ChunkTypeData {
+		o.seenData = true
+	}
+}
+
+// checkOrder reports whether a chunk of type next may follow the chunks read
+// so far.
+func checkOrder(o chunkOrder, next chunkType) error {
+	if o.read == 0 {
+		if next != ChunkTypeHeader {
+			return &OrderError{
+				Type:    next,
+				Message: fmt.Sprintf("first chunk is %s, expected IHDR", next),
+			}
+		}
+		return nil
+	}
+
+	if o.last == ChunkTypeEnd {
+		return &OrderError{
+			Type:    next,
+			Message: fmt.Sprintf("%s chunk found after IEND", next),
+		}
+	}
+
+	switch next {
+	case ChunkTypeHeader:
+		return &OrderError{Type: next, Message: "duplicate IHDR chunk"}
+	case ChunkTypePalette:
+		if o.seenData {
+			return &OrderError{Type: next, Message: "PLTE chunk found after IDAT"}
+		}
+	case ChunkTypeData:
+		if o.seenData && o.last != ChunkTypeData {
+			return &OrderError{
+				Type:    next,
+				Message: "IDAT chunks are not consecutive",
+			}
+		}
+	}
+
+	return nil
+}
+
 func getChunkType(ct []byte) chunkType {
 	if bytes.Compare(ct[:], ctHdr) == 0 {
 		return ChunkTypeHeader
@@ -419,6 +1153,83 @@ func getChunkType(ct []byte) chunkType {
 	return ChunkTypeUnknown
 }
 
+// ParseChunkType looks up the chunk type named by a four character code such as
+// "tEXt".
+func ParseChunkType(fourcc string) (chunkType, error) {
+	if len(fourcc) != 4 {
+		return ChunkTypeUnknown, fmt.Errorf("chunk type %q is not 4 characters",
+			fourcc)
+	}
+
+	ct := getChunkType([]byte(fourcc))
+	if ct == ChunkTypeUnknown {
+		return ct, fmt.Errorf("unknown chunk type %q", fourcc)
+	}
+
+	return ct, nil
+}
+
+// ChunkTypeToBytes gives the four type bytes that identify a chunk type in a
+// file. It returns nil for ChunkTypeUnknown.
+func ChunkTypeToBytes(ct chunkType) []byte {
+	t := typeBytes(ct)
+	if t == nil {
+		return nil
+	}
+
+	return append([]byte(nil), t...)
+}
+
+// typeBytes is the inverse of getChunkType. It returns nil for unknown types.
+func typeBytes(ct chunkType) []byte {
+	switch ct {
+	case ChunkTypeHeader:
+		return ctHdr
+	case ChunkTypePalette:
+		return ctPlte
+	case ChunkTypeData:
+		return ctDat
+	case ChunkTypeEnd:
+		return ctEnd
+	case ChunkTypeBkgdColor:
+		return ctBkgd
+	case ChunkTypeChromaticity:
+		return ctChrm
+	case ChunkTypeDigiSignal:
+		return ctDSig
+	case ChunkTypeExif:
+		return ctExif
+	case ChunkTypeGamma:
+		return ctGama
+	case ChunkTypeHistogram:
+		return ctHist
+	case ChunkTypeICC:
+		return ctIccp
+	case ChunkTypeTxtUTF8:
+		return ctItxt
+	case ChunkTypePxSize:
+		return ctPhys
+	case ChunkTypeSigBits:
+		return ctSbit
+	case ChunkTypeSugPalette:
+		return ctSplt
+	case ChunkTypeRGB:
+		return ctSrgb
+	case ChunkTypeStereo:
+		return ctSter
+	case ChunkTypeTxtISO8859:
+		return ctText
+	case ChunkTypeTimeChanged:
+		return ctTime
+	case ChunkTypeTransparency:
+		return ctTrns
+	case ChunkTypeTxtCompressed:
+		return ctZtxt
+	default:
+		return nil
+	}
+}
+
 func parseHeader(chunk []byte) (headerChunk, error) {
 	var hdr headerChunk
 	if l := len(chunk); l != 13 {
@@ -434,5 +1245,130 @@ func parseHeader(chunk []byte) (headerChunk, error) {
 	hdr.FilterMethod = chunk[11]
 	hdr.InterlaceMethod = chunk[12]
 
-	return hdr, nil
+	return hdr, validateHeaderChunk(hdr)
+}
+
+// ParsePaletteChunk decodes the data of a PLTE chunk into its colors, which
+// are fully opaque. Transparency for palette entries is held separately in
+// tRNS.
+func ParsePaletteChunk(chunk []byte) ([]color.RGBA, error) {
+	if err := validatePalette(chunk); err != nil {
+		return nil, err
+	}
+
+	palette := make([]color.RGBA, len(chunk)/3)
+	for i := range palette {
+		palette[i] = color.RGBA{chunk[i*3], chunk[i*3+1], chunk[i*3+2], 0xff}
+	}
+
+	return palette, nil
+}
+
+// validatePalette checks that PLTE chunk data holds between 1 and 256 complete
+// RGB entries.
+func validatePalette(chunk []byte) error {
+	if len(chunk) == 0 {
+		return &ValidationError{Kind: "InvalidPLTE", Message: "PLTE is empty"}
+	}
+	if len(chunk)%3 != 0 {
+		return &ValidationError{
+			Kind:    "InvalidPLTE",
+			Message: "PLTE length must be a multiple of 3",
+		}
+	}
+	if n := len(chunk) / 3; n > 256 {
+		return &ValidationError{
+			Kind:    "InvalidPLTE",
+			Message: fmt.Sprintf("PLTE has %d entries, at most 256 allowed", n),
+		}
+	}
+
+	return nil
+}
+
+// colorTypeNames names the valid IHDR color types
+var colorTypeNames = map[byte]string{
+	0: "grayscale",
+	2: "truecolor",
+	3: "indexed color",
+	4: "grayscale with alpha",
+	6: "truecolor with alpha",
+}
+
+// maxDimension is the largest width or height the spec allows
+const maxDimension = 1<<31 - 1
+
+// validateHeaderChunk checks that the dimensions of the header are in range,
+// that its bit depth is allowed for its color type, and that its methods are
+// ones the spec defines.
+func validateHeaderChunk(hdr headerChunk) error {
+	if hdr.Width == 0 || hdr.Height == 0 {
+		return &ValidationError{
+			Kind: "InvalidDimensions",
+			Message: fmt.Sprintf("image is %dx%d, dimensions must be at least 1",
+				hdr.Width, hdr.Height),
+		}
+	}
+	if hdr.Width > maxDimension || hdr.Height > maxDimension {
+		return &ValidationError{
+			Kind: "InvalidDimensions",
+			Message: fmt.Sprintf("image is %dx%d, dimensions must be at most %d",
+				hdr.Width, hdr.Height, maxDimension),
+		}
+	}
+
+	name, ok := colorTypeNames[hdr.ColorType]
+	if !ok {
+		return &ValidationError{
+			Kind:    "InvalidColorType",
+			Message: fmt.Sprintf("invalid color type %d", hdr.ColorType),
+		}
+	}
+
+	var depths []byte
+	switch hdr.ColorType {
+	case 0:
+		depths = []byte{1, 2, 4, 8, 16}
+	case 3:
+		depths = []byte{1, 2, 4, 8}
+	default:
+		depths = []byte{8, 16}
+	}
+
+	valid := false
+	for _, d := range depths {
+		if hdr.BitDepth == d {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return &ValidationError{
+			Kind: "InvalidBitDepth",
+			Message: fmt.Sprintf("bit depth %d is not valid for %s (type %d)",
+				hdr.BitDepth, name, hdr.ColorType),
+		}
+	}
+
+	switch {
+	case hdr.CompressionMethod != 0:
+		return &ValidationError{
+			Kind: "InvalidCompressionMethod",
+			Message: fmt.Sprintf("invalid compression method %d",
+				hdr.CompressionMethod),
+		}
+	case hdr.FilterMethod != 0:
+		return &ValidationError{
+			Kind:    "InvalidFilterMethod",
+			Message: fmt.Sprintf("invalid filter method %d", hdr.FilterMethod),
+		}
+	case InterlaceMethod(hdr.InterlaceMethod) > InterlaceAdam7:
+		return &ValidationError{
+			Kind: "InvalidInterlaceMethod",
+			Message: fmt.Sprintf("invalid interlace method %d",
+				hdr.InterlaceMethod),
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,66 @@
+package png
+
+import "testing"
+
+func TestValidateHeaderChunk(t *testing.T) {
+	valid := headerChunk{Width: 640, Height: 480, BitDepth: 8, ColorType: 6}
+	with := func(change func(*headerChunk)) headerChunk {
+		hdr := valid
+		change(&hdr)
+		return hdr
+	}
+
+	tests := []struct {
+		name string
+		hdr  headerChunk
+		// kind is the Kind of the ValidationError expected, or empty if the
+		// header is valid
+		kind string
+	}{
+		{"zero width", with(func(h *headerChunk) {
+			h.Width, h.Height = 0, 1
+		}), "InvalidDimensions"},
+		{"zero height", with(func(h *headerChunk) {
+			h.Width, h.Height = 1, 0
+		}), "InvalidDimensions"},
+		{"width over 2^31-1", with(func(h *headerChunk) {
+			h.Width, h.Height = 1<<31, 1
+		}), "InvalidDimensions"},
+		{"color type", with(func(h *headerChunk) {
+			h.ColorType = 5
+		}), "InvalidColorType"},
+		{"bit depth", with(func(h *headerChunk) {
+			h.BitDepth = 4
+		}), "InvalidBitDepth"},
+		{"compression method", with(func(h *headerChunk) {
+			h.CompressionMethod = 1
+		}), "InvalidCompressionMethod"},
+		{"filter method", with(func(h *headerChunk) {
+			h.FilterMethod = 1
+		}), "InvalidFilterMethod"},
+		{"interlace method", with(func(h *headerChunk) {
+			h.InterlaceMethod = 2
+		}), "InvalidInterlaceMethod"},
+		{"valid", valid, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHeaderChunk(tt.hdr)
+			if tt.kind == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				return
+			}
+
+			ve, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("got error %v, want a ValidationError", err)
+			}
+			if ve.Kind != tt.kind {
+				t.Errorf("got kind %q, want %q", ve.Kind, tt.kind)
+			}
+		})
+	}
+}
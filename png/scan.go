@@ -0,0 +1,73 @@
+package png
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ScanChunkTypes lists the types of the chunks in the PNG read from r, in file
+// order, without keeping any chunk data. Only the length and type of each
+// chunk are read; its data and CRC are discarded, so memory use does not grow
+// with the size of the image. CRCs are not checked. Types are returned for the
+// chunks read before any error.
+func ScanChunkTypes(r io.Reader) ([]chunkType, error) {
+	var types []chunkType
+
+	sig := make([]byte, len(PNGSignature))
+	if err := readField(r, sig, "signature", 0); err != nil {
+		return types, err
+	}
+	if !IsPNGSignature(sig) {
+		return types, errors.New("input not a PNG")
+	}
+
+	offset := int64(len(sig))
+	var foundEnd bool
+	head := make([]byte, 8)
+	for {
+		// The input may only end between chunks
+		err := readField(r, head[:4], "length", offset)
+		if te, ok := err.(*TruncationError); ok && te.Got == 0 {
+			break
+		}
+		if err != nil {
+			return types, err
+		}
+		if err := readField(r, head[4:], "type", offset); err != nil {
+			return types, err
+		}
+
+		l := binary.BigEndian.Uint32(head[:4])
+		if l > maxChunkLength {
+			return types, &ParseError{
+				Kind: "ChunkTooLarge",
+				Message: fmt.Sprintf("%s chunk declares %d bytes, spec limit is %d",
+					head[4:], l, maxChunkLength),
+			}
+		}
+		if err := skipField(r, int64(l), "data", offset); err != nil {
+			return types, err
+		}
+		if err := skipField(r, 4, "CRC", offset); err != nil {
+			return types, err
+		}
+
+		ct := getChunkType(head[4:])
+		if ct == ChunkTypeEnd {
+			foundEnd = true
+		}
+		types = append(types, ct)
+		offset += 12 + int64(l)
+	}
+
+	if !foundEnd {
+		return types, &ValidationError{
+			Kind:    "MissingIEND",
+			Message: "input ended without an IEND chunk",
+		}
+	}
+
+	return types, nil
+}
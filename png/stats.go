@@ -0,0 +1,174 @@
+package png
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Statistics summarizes the chunks making up a parsed file.
+type Statistics struct {
+	// Chunks is the total number of chunks in the file
+	Chunks int
+	// TextChunks counts the tEXt, zTXt, and iTXt chunks
+	TextChunks int
+	// UnknownChunks counts the chunks whose type the parser does not recognize
+	UnknownChunks int
+	// MetadataBytes is the combined data length of the metadata chunks, as
+	// selected by FilterMetadata
+	MetadataBytes int64
+	// DataBytes is the combined data length of the IDAT chunks
+	DataBytes int64
+}
+
+// Stats gathers statistics about the parsed chunks.
+func (p *Parser) Stats() Statistics {
+	var s Statistics
+	for _, ch := range p.data {
+		s.Chunks++
+
+		if FilterTextChunks(ch) {
+			s.TextChunks++
+		}
+		if ch.Type == ChunkTypeUnknown {
+			s.UnknownChunks++
+		}
+		if FilterMetadata(ch) {
+			s.MetadataBytes += int64(len(ch.Data))
+		}
+		if ch.Type == ChunkTypeData {
+			s.DataBytes += int64(len(ch.Data))
+		}
+	}
+
+	return s
+}
+
+// MetadataSize gives the number of bytes the metadata chunks, as selected by
+// FilterMetadata, take up in the file. Unlike Statistics.MetadataBytes this
+// counts the 12 bytes of length, type, and CRC around each chunk's data.
+func (p *Parser) MetadataSize() int64 {
+	var size int64
+	for _, ch := range p.data {
+		if FilterMetadata(ch) {
+			size += int64(12 + len(ch.Data))
+		}
+	}
+
+	return size
+}
+
+// ComplexityScore rates how much cleanup a file is likely to need, so batch
+// tools can decide which files to process first. It is computed as
+//
+//	TextChunks*2 + (MetadataBytes/DataBytes)*10 + UnknownChunks*5
+//
+// Text chunks are the most common carriers of stray information, a high
+// metadata-to-data ratio means stripping saves a large share of the file, and
+// unknown chunks are weighted highest since nothing can tell what they hold.
+// Files without image data count the metadata ratio as if there were one byte
+// of it.
+func (s Statistics) ComplexityScore() float64 {
+	data := s.DataBytes
+	if data == 0 {
+		data = 1
+	}
+
+	return float64(s.TextChunks)*2 +
+		float64(s.MetadataBytes)/float64(data)*10 +
+		float64(s.UnknownChunks)*5
+}
+
+// String formats the statistics on a single line
+func (s Statistics) String() string {
+	return fmt.Sprintf("%d chunks (%d text, %d unknown), "+
+		"%d metadata bytes, %d data bytes, complexity %.2f",
+		s.Chunks, s.TextChunks, s.UnknownChunks,
+		s.MetadataBytes, s.DataBytes, s.ComplexityScore())
+}
+
+// ImageStats summarizes the pixels of an image.
+type ImageStats struct {
+	// AverageColor is the mean of each channel over the sampled pixels
+	AverageColor color.NRGBA
+	// DominantPaletteIndex is the palette entry used by the most sampled
+	// pixels of an indexed color image, or -1 for other images
+	DominantPaletteIndex int
+	// AlphaCoverage is the fraction of sampled pixels that are not fully
+	// opaque
+	AlphaCoverage float64
+	// HasTransparentPixels reports whether any sampled pixel is fully
+	// transparent
+	HasTransparentPixels bool
+	// SampledPixels is the number of pixels the statistics were computed from
+	SampledPixels int
+}
+
+// ImageStatistics decodes the image data and computes statistics about its
+// pixels. Every pixel is looked at unless a sample rate was set with
+// WithSampleRate.
+func (p *Parser) ImageStatistics() (ImageStats, error) {
+	stats := ImageStats{DominantPaletteIndex: -1}
+	d, err := p.newDecoder()
+	if err != nil {
+		return stats, err
+	}
+
+	rate := p.sampleRate
+	if rate < 1 {
+		rate = 1
+	}
+
+	var sums [4]uint64
+	var translucent, seen int
+	uses := make([]int, len(d.palette))
+	err = d.decode(p, func(x, y int, line []byte, col int) error {
+		seen++
+		if (seen-1)%rate != 0 {
+			return nil
+		}
+
+		c, err := d.pixel(line, col)
+		if err != nil {
+			return err
+		}
+		if d.hdr.ColorType == 3 {
+			uses[d.sample(line, col)]++
+		}
+
+		nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+		sums[0] += uint64(nrgba.R)
+		sums[1] += uint64(nrgba.G)
+		sums[2] += uint64(nrgba.B)
+		sums[3] += uint64(nrgba.A)
+		if nrgba.A < 0xff {
+			translucent++
+		}
+		if nrgba.A == 0 {
+			stats.HasTransparentPixels = true
+		}
+		stats.SampledPixels++
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	if stats.SampledPixels == 0 {
+		return stats, nil
+	}
+
+	n := uint64(stats.SampledPixels)
+	stats.AverageColor = color.NRGBA{
+		uint8(sums[0] / n), uint8(sums[1] / n), uint8(sums[2] / n),
+		uint8(sums[3] / n),
+	}
+	stats.AlphaCoverage = float64(translucent) / float64(stats.SampledPixels)
+
+	for i, u := range uses {
+		if u > 0 && (stats.DominantPaletteIndex < 0 ||
+			u > uses[stats.DominantPaletteIndex]) {
+			stats.DominantPaletteIndex = i
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,396 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextChunk holds the decoded contents of a tEXt or zTXt chunk.
+type TextChunk struct {
+	Keyword string
+	Text    string
+}
+
+// ITXtChunk holds the decoded contents of an iTXt chunk. It contains (in this
+// order) the keyword, compression flag, compression method, language tag,
+// translated keyword, and the UTF-8 text.
+type ITXtChunk struct {
+	Keyword           string
+	CompressionFlag   byte
+	CompressionMethod byte
+	LanguageTag       string
+	TranslatedKeyword string
+	Text              string
+}
+
+// textKeyword extracts the keyword that begins the data of tEXt, iTXt, and
+// zTXt chunks, along with the index of the null separator that terminates it.
+// The keyword is encoded in Latin-1, so its length as a string may differ from
+// the index.
+func textKeyword(data []byte) (string, int, error) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return "", i, fmt.Errorf("no null separator after text keyword")
+	}
+
+	return latin1(data[:i]), i, nil
+}
+
+// latin1 decodes ISO/IEC 8859-1 bytes into a string.
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+
+	return string(r)
+}
+
+// encodeLatin1 encodes a string as ISO/IEC 8859-1 bytes, failing on characters
+// outside that set.
+func encodeLatin1(s string) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, fmt.Errorf("character %q is not in Latin-1", r)
+		}
+		b = append(b, byte(r))
+	}
+
+	return b, nil
+}
+
+// TextKeys returns the keywords of all text chunks in the order they first
+// appear in the file. Each keyword is listed once.
+func (p *Parser) TextKeys() ([]string, error) {
+	var keys []string
+	seen := make(map[string]bool)
+
+	for _, ch := range p.data {
+		if !FilterTextChunks(ch) {
+			continue
+		}
+
+		key, _, err := textKeyword(ch.Data)
+		if err != nil {
+			return keys, fmt.Errorf("unable to read %s keyword: %v", ch.Type, err)
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// ParseTextChunk decodes the data of a tEXt chunk.
+func ParseTextChunk(chunk []byte) (TextChunk, error) {
+	var txt TextChunk
+
+	key, i, err := textKeyword(chunk)
+	if err != nil {
+		return txt, err
+	}
+	if err := ValidateTextKeyword(key); err != nil {
+		return txt, err
+	}
+
+	txt.Keyword = key
+	txt.Text = latin1(chunk[i+1:])
+	return txt, nil
+}
+
+// ParseZTXtChunk decodes the data of a zTXt chunk, decompressing its text.
+func ParseZTXtChunk(chunk []byte) (TextChunk, error) {
+	var txt TextChunk
+
+	key, i, err := textKeyword(chunk)
+	if err != nil {
+		return txt, err
+	}
+	if err := ValidateTextKeyword(key); err != nil {
+		return txt, err
+	}
+
+	rest := chunk[i+1:]
+	if len(rest) < 1 {
+		return txt, fmt.Errorf("zTXt chunk missing compression method")
+	}
+	if rest[0] != 0 {
+		return txt, fmt.Errorf("unknown zTXt compression method %d", rest[0])
+	}
+
+	text, err := inflate(rest[1:])
+	if err != nil {
+		return txt, fmt.Errorf("unable to decompress zTXt text: %v", err)
+	}
+
+	txt.Keyword = key
+	txt.Text = latin1(text)
+	return txt, nil
+}
+
+// ParseITXtChunk decodes the data of an iTXt chunk, decompressing its text if
+// the compression flag is set.
+func ParseITXtChunk(chunk []byte) (ITXtChunk, error) {
+	var txt ITXtChunk
+
+	key, i, err := textKeyword(chunk)
+	if err != nil {
+		return txt, err
+	}
+	if err := ValidateTextKeyword(key); err != nil {
+		return txt, err
+	}
+	txt.Keyword = key
+
+	rest := chunk[i+1:]
+	if len(rest) < 2 {
+		return txt, fmt.Errorf("iTXt chunk missing compression fields")
+	}
+	txt.CompressionFlag = rest[0]
+	txt.CompressionMethod = rest[1]
+	rest = rest[2:]
+
+	i = bytes.IndexByte(rest, 0)
+	if i < 0 {
+		return txt, fmt.Errorf("no null separator after iTXt language tag")
+	}
+	txt.LanguageTag = string(rest[:i])
+	rest = rest[i+1:]
+	if err := validateLanguageTag(txt.LanguageTag); err != nil {
+		return txt, err
+	}
+
+	i = bytes.IndexByte(rest, 0)
+	if i < 0 {
+		return txt, fmt.Errorf("no null separator after iTXt translated keyword")
+	}
+	txt.TranslatedKeyword = string(rest[:i])
+	rest = rest[i+1:]
+
+	switch txt.CompressionFlag {
+	case 0:
+		txt.Text = string(rest)
+	case 1:
+		if txt.CompressionMethod != 0 {
+			return txt, &ValidationError{
+				Kind: "InvalidITXt",
+				Message: fmt.Sprintf("unknown iTXt compression method %d",
+					txt.CompressionMethod),
+			}
+		}
+		text, err := inflate(rest)
+		if err != nil {
+			return txt, fmt.Errorf("unable to decompress iTXt text: %v", err)
+		}
+		txt.Text = string(text)
+	default:
+		return txt, fmt.Errorf("invalid iTXt compression flag %d",
+			txt.CompressionFlag)
+	}
+
+	return txt, nil
+}
+
+// maxKeyword is the longest keyword a text chunk may hold
+const maxKeyword = 79
+
+// ValidateTextKeyword checks a text chunk keyword against the spec: it must be
+// 1 to 79 bytes when encoded as Latin-1, hold no null bytes, and not begin or
+// end with a space.
+func ValidateTextKeyword(keyword string) error {
+	invalid := func(format string, args ...interface{}) error {
+		return &ValidationError{
+			Kind:    "InvalidTextKeyword",
+			Message: fmt.Sprintf(format, args...),
+		}
+	}
+
+	if strings.IndexByte(keyword, 0) >= 0 {
+		return invalid("keyword %q contains a null byte", keyword)
+	}
+	encoded, err := encodeLatin1(keyword)
+	if err != nil {
+		return invalid("keyword %q is not Latin-1: %v", keyword, err)
+	}
+	if l := len(encoded); l < 1 || l > maxKeyword {
+		return invalid("keyword is %d bytes, must be 1 to %d", l, maxKeyword)
+	}
+	if keyword[0] == ' ' || keyword[len(keyword)-1] == ' ' {
+		return invalid("keyword %q begins or ends with a space", keyword)
+	}
+
+	return nil
+}
+
+// encodeKeyword validates a text chunk keyword and encodes it as Latin-1.
+func encodeKeyword(keyword string) ([]byte, error) {
+	if err := ValidateTextKeyword(keyword); err != nil {
+		return nil, err
+	}
+
+	return encodeLatin1(keyword)
+}
+
+// NewTextChunk builds a tEXt chunk. The keyword and text must be encodable as
+// Latin-1.
+func NewTextChunk(keyword, text string) (Chunk, error) {
+	key, err := encodeKeyword(keyword)
+	if err != nil {
+		return Chunk{}, err
+	}
+	value, err := encodeLatin1(text)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("invalid tEXt text: %v", err)
+	}
+
+	data := append(append(key, 0), value...)
+	return NewChunk(ChunkTypeTxtISO8859, data)
+}
+
+// NewZTXtChunk builds a zTXt chunk holding the text compressed. The keyword and
+// text must be encodable as Latin-1.
+func NewZTXtChunk(keyword, text string) (Chunk, error) {
+	key, err := encodeKeyword(keyword)
+	if err != nil {
+		return Chunk{}, err
+	}
+	value, err := encodeLatin1(text)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("invalid zTXt text: %v", err)
+	}
+	compressed, err := deflate(value)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("unable to compress zTXt text: %v", err)
+	}
+
+	data := append(append(key, 0, 0), compressed...)
+	return NewChunk(ChunkTypeTxtCompressed, data)
+}
+
+// NewITXtChunk builds an iTXt chunk. The keyword must be encodable as Latin-1,
+// while the translated keyword and text may hold any UTF-8. The language tag
+// may be empty. The text is compressed if compress is set.
+func NewITXtChunk(keyword, languageTag, translatedKeyword, text string,
+	compress bool) (Chunk, error) {
+	key, err := encodeKeyword(keyword)
+	if err != nil {
+		return Chunk{}, err
+	}
+	if err := validateLanguageTag(languageTag); err != nil {
+		return Chunk{}, err
+	}
+	if strings.IndexByte(translatedKeyword, 0) >= 0 {
+		return Chunk{}, errors.New("translated keyword contains a null byte")
+	}
+	if !utf8.ValidString(translatedKeyword) || !utf8.ValidString(text) {
+		return Chunk{}, errors.New("iTXt text is not valid UTF-8")
+	}
+
+	value := []byte(text)
+	var flag byte
+	if compress {
+		if value, err = deflate(value); err != nil {
+			return Chunk{}, fmt.Errorf("unable to compress iTXt text: %v", err)
+		}
+		flag = 1
+	}
+
+	var data bytes.Buffer
+	data.Write(key)
+	data.Write([]byte{0, flag, 0})
+	data.WriteString(languageTag)
+	data.WriteByte(0)
+	data.WriteString(translatedKeyword)
+	data.WriteByte(0)
+	data.Write(value)
+
+	return NewChunk(ChunkTypeTxtUTF8, data.Bytes())
+}
+
+// validateLanguageTag checks that an iTXt language tag holds only the letters,
+// digits, and hyphens allowed in RFC 3066 tags such as "en-US".
+func validateLanguageTag(tag string) error {
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			c >= '0' && c <= '9' || c == '-') {
+			return &ValidationError{
+				Kind:    "InvalidITXt",
+				Message: fmt.Sprintf("invalid character %q in iTXt language tag", c),
+			}
+		}
+	}
+
+	return nil
+}
+
+// inflate decompresses a zlib stream held in memory.
+func inflate(b []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
+
+// deflate compresses b as a zlib stream.
+func deflate(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ForEachText hands the keyword and decoded value of each tEXt, iTXt, and zTXt
+// chunk to fn in file order. Iteration stops when fn returns false. Any chunk
+// that fails to decode stops iteration and its error is returned.
+func (p *Parser) ForEachText(fn func(keyword, value string) bool) error {
+	for _, ch := range p.data {
+		var keyword, value string
+
+		switch ch.Type {
+		case ChunkTypeTxtISO8859:
+			txt, err := ParseTextChunk(ch.Data)
+			if err != nil {
+				return fmt.Errorf("unable to parse tEXt chunk: %v", err)
+			}
+			keyword, value = txt.Keyword, txt.Text
+		case ChunkTypeTxtCompressed:
+			txt, err := ParseZTXtChunk(ch.Data)
+			if err != nil {
+				return fmt.Errorf("unable to parse zTXt chunk: %v", err)
+			}
+			keyword, value = txt.Keyword, txt.Text
+		case ChunkTypeTxtUTF8:
+			txt, err := ParseITXtChunk(ch.Data)
+			if err != nil {
+				return fmt.Errorf("unable to parse iTXt chunk: %v", err)
+			}
+			keyword, value = txt.Keyword, txt.Text
+		default:
+			continue
+		}
+
+		if cont := fn(keyword, value); !cont {
+			break
+		}
+	}
+
+	return nil
+}
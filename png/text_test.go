@@ -0,0 +1,55 @@
+package png
+
+import "testing"
+
+func TestParseTextChunkLatin1Keyword(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		text string
+	}{
+		{"with text", "Sch\xf6pfer\x00Hello", "Hello"},
+		{"empty text", "Sch\xf6pfer\x00", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txt, err := ParseTextChunk([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("got error %v, want none", err)
+			}
+			if txt.Keyword != "Schöpfer" {
+				t.Errorf("got keyword %q, want %q", txt.Keyword, "Schöpfer")
+			}
+			if txt.Text != tt.text {
+				t.Errorf("got text %q, want %q", txt.Text, tt.text)
+			}
+		})
+	}
+}
+
+func TestValidateLatin1Keyword(t *testing.T) {
+	hdr, err := NewHeaderChunk(1, 1, 8, ColorTypeGrayscale, 0, 0, InterlaceNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ztxt, err := NewZTXtChunk("Schöpfer", "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	itxt, err := NewITXtChunk("Schöpfer", "de", "Autor", "Hallo", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := NewTextChunk("Schöpfer", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Parser{parsed: true}
+	p.data = []Chunk{hdr, text, ztxt, itxt}
+
+	for _, err := range p.Validate() {
+		t.Errorf("got error %v, want none", err)
+	}
+}
@@ -0,0 +1,71 @@
+package png
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate checks the parsed chunks against the rules of the PNG spec and
+// returns every problem found. A valid file returns no errors.
+func (p *Parser) Validate() []error {
+	if !p.parsed {
+		return []error{errors.New("parser has not parsed its input")}
+	}
+
+	var errs []error
+	hdr, err := p.Header()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	var palette []Chunk
+	for _, ch := range p.data {
+		switch ch.Type {
+		case ChunkTypePalette:
+			if err := validatePalette(ch.Data); err != nil {
+				errs = append(errs, err)
+			}
+			palette = append(palette, ch)
+		case ChunkTypeHistogram:
+			if len(palette) == 0 {
+				errs = append(errs, &OrderError{
+					Type:    ch.Type,
+					Message: "hIST chunk found without a preceding PLTE",
+				})
+			}
+		case ChunkTypeTransparency:
+			if err == nil && (hdr.ColorType == 4 || hdr.ColorType == 6) {
+				errs = append(errs, &ValidationError{
+					Kind: "InvalidTRNS",
+					Message: fmt.Sprintf("tRNS chunk not allowed for %s (type %d)",
+						colorTypeNames[hdr.ColorType], hdr.ColorType),
+				})
+			}
+		case ChunkTypeTxtISO8859:
+			if _, err := ParseTextChunk(ch.Data); err != nil {
+				errs = append(errs, err)
+			}
+		case ChunkTypeTxtCompressed:
+			if _, err := ParseZTXtChunk(ch.Data); err != nil {
+				errs = append(errs, err)
+			}
+		case ChunkTypeTxtUTF8:
+			if _, err := ParseITXtChunk(ch.Data); err != nil {
+				errs = append(errs, err)
+			}
+		case ChunkTypeBkgdColor:
+			if err == nil && hdr.ColorType == 3 && len(palette) > 0 &&
+				len(ch.Data) == 1 {
+				if n := len(palette[0].Data) / 3; int(ch.Data[0]) >= n {
+					errs = append(errs, &ValidationError{
+						Kind: "InvalidBKGD",
+						Message: fmt.Sprintf("bKGD palette index %d is out of range "+
+							"for %d PLTE entries", ch.Data[0], n),
+					})
+				}
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,167 @@
+package png
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// VerificationError describes one problem Verify found in a file.
+type VerificationError struct {
+	// Offset is the position in the file of the chunk at fault, or where the
+	// input ended for problems with the file as a whole
+	Offset int64
+	// Kind identifies the problem, e.g. "CRCMismatch"
+	Kind    string
+	Message string
+}
+
+func (e VerificationError) Error() string {
+	return fmt.Sprintf("offset 0x%x: %s: %s", e.Offset, e.Kind, e.Message)
+}
+
+// Verify checks the integrity of the PNG read from r: its signature, the CRC
+// of every chunk, the order of the chunks, and the fields of IHDR. Unlike
+// parsing, it carries on past problems and reports all of them, sorted by
+// offset. An intact file returns no errors. Chunk data is checked as it is
+// read rather than kept, so memory use does not grow with the size of the
+// image. Reading stops at the first chunk that is truncated or too large to be
+// valid, since the chunks after it cannot be found.
+func Verify(r io.Reader) []VerificationError {
+	var errs []VerificationError
+	fail := func(offset int64, kind, message string) {
+		errs = append(errs, VerificationError{offset, kind, message})
+	}
+
+	sig := make([]byte, len(PNGSignature))
+	if err := readField(r, sig, "signature", 0); err != nil {
+		fail(0, "Truncated", "input ended before the PNG signature")
+		return errs
+	}
+	if !IsPNGSignature(sig) {
+		fail(0, "InvalidSignature", fmt.Sprintf("signature is % x", sig))
+	}
+
+	offset := int64(len(sig))
+	var order chunkOrder
+	var foundHeader, foundData, foundEnd bool
+	head := make([]byte, 8)
+	for {
+		// The input may only end between chunks
+		err := readField(r, head[:4], "length", offset)
+		if te, ok := err.(*TruncationError); ok && te.Got == 0 {
+			break
+		}
+		if err == nil {
+			err = readField(r, head[4:], "type", offset)
+		}
+		if err != nil {
+			fail(offset, readErrorKind(err), err.Error())
+			return sortVerificationErrors(errs)
+		}
+
+		l := binary.BigEndian.Uint32(head[:4])
+		if l > maxChunkLength {
+			fail(offset, "ChunkTooLarge",
+				fmt.Sprintf("%s chunk declares %d bytes, spec limit is %d",
+					head[4:], l, maxChunkLength))
+			return sortVerificationErrors(errs)
+		}
+
+		ct := getChunkType(head[4:])
+		if err := checkOrder(order, ct); err != nil {
+			fail(offset, "ChunkOrder", err.Error())
+		}
+
+		// Only IHDR data is kept, and only when it is the right size to parse
+		h := crc32.NewIEEE()
+		h.Write(head[4:])
+		var data bytes.Buffer
+		w := io.Writer(h)
+		if ct == ChunkTypeHeader && l == 13 {
+			w = io.MultiWriter(h, &data)
+		}
+		if err := copyField(w, r, int64(l), "data", offset); err != nil {
+			fail(offset, readErrorKind(err), err.Error())
+			return sortVerificationErrors(errs)
+		}
+
+		var crc [4]byte
+		if err := readField(r, crc[:], "CRC", offset); err != nil {
+			fail(offset, readErrorKind(err), err.Error())
+			return sortVerificationErrors(errs)
+		}
+		if binary.BigEndian.Uint32(crc[:]) != h.Sum32() {
+			fail(offset, "CRCMismatch", fmt.Sprintf(
+				"%s chunk stores CRC %08x, computed %08x", head[4:],
+				binary.BigEndian.Uint32(crc[:]), h.Sum32()))
+		}
+
+		switch ct {
+		case ChunkTypeHeader:
+			if !foundHeader {
+				foundHeader = true
+				if err := verifyHeader(data.Bytes(), l); err != nil {
+					errs = append(errs, headerVerificationError(offset, err))
+				}
+			}
+		case ChunkTypeData:
+			foundData = true
+		case ChunkTypeEnd:
+			foundEnd = true
+		}
+
+		order.add(ct)
+		offset += 12 + int64(l)
+	}
+
+	if !foundHeader {
+		fail(offset, "MissingIHDR", "input has no IHDR chunk")
+	}
+	if !foundData {
+		fail(offset, "MissingIDAT", "input has no IDAT chunk")
+	}
+	if !foundEnd {
+		fail(offset, "MissingIEND", "input ended without an IEND chunk")
+	}
+
+	return sortVerificationErrors(errs)
+}
+
+// verifyHeader checks the data of an IHDR chunk declaring l bytes.
+func verifyHeader(data []byte, l uint32) error {
+	if l != 13 {
+		return fmt.Errorf("got %d bytes for header chunk, expected %d", l, 13)
+	}
+	_, err := parseHeader(data)
+	return err
+}
+
+// readErrorKind gives the kind of a VerificationError for a failed read.
+func readErrorKind(err error) string {
+	if _, ok := err.(*TruncationError); ok {
+		return "Truncated"
+	}
+	return "ReadError"
+}
+
+// headerVerificationError describes an invalid IHDR at offset, keeping the
+// kind of a ValidationError.
+func headerVerificationError(offset int64, err error) VerificationError {
+	if ve, ok := err.(*ValidationError); ok {
+		return VerificationError{offset, ve.Kind, ve.Message}
+	}
+	return VerificationError{offset, "InvalidIHDR", err.Error()}
+}
+
+// sortVerificationErrors orders errors by offset, keeping errors at the same
+// offset in the order they were found.
+func sortVerificationErrors(errs []VerificationError) []VerificationError {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Offset < errs[j].Offset
+	})
+	return errs
+}
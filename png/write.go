@@ -0,0 +1,194 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// defaultIDATChunkSize is the size IDAT data is split into when it has to be
+// rewritten and no other size was given, matching libpng.
+const defaultIDATChunkSize = 1 << 15
+
+// WriteTo writes the parsed chunks out as a PNG file, applying any output
+// options set on the parser. Chunks of an unknown type are written with the
+// type bytes they were read with; any that were not read from a file are left
+// out. It implements io.WriterTo.
+func (p *Parser) WriteTo(w io.Writer) (int64, error) {
+	chunks, err := p.outputChunks(func(ch Chunk) bool {
+		return ch.typeBytes() != nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return writeChunks(w, chunks)
+}
+
+// WriteSignature writes the 8 byte PNG signature that must begin every PNG
+// file. Writing it followed by each chunk in order produces a complete file.
+func WriteSignature(w io.Writer) (int, error) {
+	return w.Write(PNGSignature[:])
+}
+
+// outputChunks selects the chunks to write and applies any output options set
+// on the parser.
+func (p *Parser) outputChunks(keep ChunkFilter) ([]Chunk, error) {
+	var chunks []Chunk
+	for _, ch := range p.data {
+		if keep(ch) {
+			chunks = append(chunks, ch)
+		}
+	}
+	if p.canonical {
+		chunks = canonicalOrder(chunks)
+	}
+
+	size := p.idatChunkSize
+	if size <= 0 {
+		size = defaultIDATChunkSize
+	}
+
+	switch {
+	case p.recompress:
+		return recompressData(chunks, p.compressLevel, size)
+	case p.idatChunkSize > 0:
+		return replaceData(chunks, dataStream(chunks), size), nil
+	default:
+		return chunks, nil
+	}
+}
+
+// writeChunks writes the PNG signature followed by each chunk, returning the
+// number of bytes written.
+func writeChunks(w io.Writer, chunks []Chunk) (int64, error) {
+	n, err := WriteSignature(w)
+	total := int64(n)
+	if err != nil {
+		return total, fmt.Errorf("unable to write PNG header: %v", err)
+	}
+
+	for _, ch := range chunks {
+		n, err := ch.WriteTo(w)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// WriteTo writes the chunk as it appears in a file: its length, type, data, and
+// CRC. The length and CRC are written as stored, so use CorrectCRCs first to
+// repair a corrupt chunk. It implements io.WriterTo.
+func (ch Chunk) WriteTo(w io.Writer) (int64, error) {
+	t := ch.typeBytes()
+	if t == nil {
+		return 0, errors.New("unable to write chunk: type bytes of unknown " +
+			"chunk not known")
+	}
+
+	var total int64
+	for _, field := range []struct {
+		name string
+		b    []byte
+	}{
+		{"length", ch.Length[:]},
+		{"type", t},
+		{"data", ch.Data},
+		{"CRC", ch.CRC[:]},
+	} {
+		n, err := w.Write(field.b)
+		total += int64(n)
+		if err != nil {
+			return total, fmt.Errorf("unable to write chunk %s: %v", field.name,
+				err)
+		}
+	}
+
+	return total, nil
+}
+
+// recompressData replaces the IDAT chunks with the image data decompressed and
+// compressed again at the given level, split into chunks of at most size
+// bytes.
+func recompressData(chunks []Chunk, level int, size int) ([]Chunk, error) {
+	stream := dataStream(chunks)
+	if len(stream) == 0 {
+		return chunks, nil
+	}
+
+	raw, err := inflate(stream)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress image data: %v", err)
+	}
+
+	var compressed bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&compressed, level)
+	if err != nil {
+		return nil, fmt.Errorf("unable to recompress image data: %v", err)
+	}
+	if _, err := zw.Write(raw); err != nil {
+		return nil, fmt.Errorf("unable to recompress image data: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to recompress image data: %v", err)
+	}
+
+	return replaceData(chunks, compressed.Bytes(), size), nil
+}
+
+// dataStream concatenates the data of all IDAT chunks.
+func dataStream(chunks []Chunk) []byte {
+	var stream bytes.Buffer
+	for _, ch := range chunks {
+		if ch.Type == ChunkTypeData {
+			stream.Write(ch.Data)
+		}
+	}
+
+	return stream.Bytes()
+}
+
+// replaceData swaps the IDAT chunks for new ones holding data, split into
+// chunks of at most size bytes. The new chunks take the place of the first
+// IDAT chunk.
+func replaceData(chunks []Chunk, data []byte, size int) []Chunk {
+	var out []Chunk
+	inserted := false
+	for _, ch := range chunks {
+		if ch.Type != ChunkTypeData {
+			out = append(out, ch)
+			continue
+		}
+		if inserted {
+			continue
+		}
+
+		out = append(out, splitData(data, size)...)
+		inserted = true
+	}
+
+	return out
+}
+
+// splitData builds IDAT chunks holding at most size bytes of data each.
+func splitData(data []byte, size int) []Chunk {
+	var chunks []Chunk
+	for len(data) > 0 {
+		n := size
+		if n > len(data) {
+			n = len(data)
+		}
+
+		ch := Chunk{Type: ChunkTypeData, Data: data[:n]}
+		ch.reseal()
+		chunks = append(chunks, ch)
+		data = data[n:]
+	}
+
+	return chunks
+}
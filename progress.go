@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"gitlab.com/thedahv/pnguin/internal/ansi"
+)
+
+// progressReporter prints a running count of the files handled in a batch. On
+// a terminal each report overwrites the last; otherwise every report is kept
+// on its own line so logs show the full history.
+type progressReporter struct {
+	w     io.Writer
+	tty   bool
+	done  int
+	total int
+}
+
+// newProgressReporter reports progress through total files to f.
+func newProgressReporter(f *os.File, total int) *progressReporter {
+	return &progressReporter{w: f, tty: ansi.IsTerminal(f), total: total}
+}
+
+// report notes that the file at path is being handled.
+func (r *progressReporter) report(path string) {
+	r.done++
+	if r.tty {
+		fmt.Fprintf(r.w, "\r%s[%d/%d] %s", ansi.ClearLine, r.done, r.total, path)
+		return
+	}
+
+	fmt.Fprintf(r.w, "[%d/%d] %s\n", r.done, r.total, path)
+}
+
+// finish ends the last report on a terminal so later output starts on a new
+// line.
+func (r *progressReporter) finish() {
+	if r.tty && r.done > 0 {
+		fmt.Fprintln(r.w)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"gitlab.com/thedahv/pnguin/png"
+)
+
+// siSizes selects SI prefixes (1 KB = 1000 bytes) over binary prefixes
+// (1 KiB = 1024 bytes) when formatting sizes
+var siSizes bool
+
+// formatSize renders a byte count for people, e.g. "12 B", "456 KiB", or
+// "2.3 MiB". Values under 10 keep one decimal place.
+func formatSize(n int64) string {
+	base, units := 1024.0, []string{"KiB", "MiB", "GiB", "TiB"}
+	if siSizes {
+		base, units = 1000.0, []string{"KB", "MB", "GB", "TB"}
+	}
+
+	if float64(n) < base && float64(n) > -base {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	v, unit := float64(n)/base, units[0]
+	for _, u := range units[1:] {
+		if v < base && v > -base {
+			break
+		}
+		v, unit = v/base, u
+	}
+
+	if v < 10 && v > -10 {
+		return fmt.Sprintf("%.1f %s", v, unit)
+	}
+	return fmt.Sprintf("%.0f %s", v, unit)
+}
+
+// fileSize gives the size of the image's file, or the size it would have when
+// written out as parsed if it was not read from a file.
+func fileSize(p *png.Parser) int64 {
+	size, err := p.SizeOnDisk()
+	if err == nil {
+		return size
+	}
+
+	size = int64(len(png.PNGSignature))
+	p.WalkChunks(func(ch png.Chunk) bool {
+		size += int64(12 + len(ch.Data))
+		return true
+	})
+	return size
+}
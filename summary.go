@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gitlab.com/thedahv/pnguin/png"
+)
+
+// notAvailable fills summary fields that cannot be determined for an image
+const notAvailable = "N/A"
+
+// printSummary writes a single tab-separated line describing a parsed image:
+// its path, dimensions, bit depth, color type, chunk count, text chunk count,
+// whether it holds an ICC profile or EXIF data, its resolution in DPI, and its
+// size in bytes.
+func printSummary(w io.Writer, p *png.Parser) {
+	dims, depth, colorType := notAvailable, notAvailable, notAvailable
+	if hdr, err := p.Header(); err == nil {
+		dims = fmt.Sprintf("%dx%d", hdr.Width, hdr.Height)
+		depth = fmt.Sprint(hdr.BitDepth)
+		colorType = fmt.Sprint(hdr.ColorType)
+	}
+
+	dpi := notAvailable
+	if ch, ok := firstChunk(p, "pHYs"); ok {
+		if phys, err := png.ParsePhysChunk(ch.Data); err == nil {
+			if x, y, ok := phys.DPI(); ok {
+				dpi = fmt.Sprintf("%.0fx%.0f", x, y)
+				if fmt.Sprintf("%.0f", x) == fmt.Sprintf("%.0f", y) {
+					dpi = fmt.Sprintf("%.0f", x)
+				}
+			}
+		}
+	}
+
+	size := fileSize(p)
+
+	stats := p.Stats()
+	fields := []string{
+		p.Path,
+		dims,
+		depth,
+		colorType,
+		fmt.Sprint(stats.Chunks),
+		fmt.Sprint(stats.TextChunks),
+		fmt.Sprint(hasChunk(p, "iCCP")),
+		fmt.Sprint(hasChunk(p, "eXIf")),
+		dpi,
+		fmt.Sprint(size),
+	}
+
+	fmt.Fprintln(w, strings.Join(fields, "\t"))
+}
+
+// firstChunk finds the first chunk whose type bytes are fourcc, without
+// copying the data of every chunk of that type as GetChunksByType does.
+func firstChunk(p *png.Parser, fourcc string) (png.Chunk, bool) {
+	var found png.Chunk
+	ok := false
+	p.WalkChunks(func(ch png.Chunk) bool {
+		if ch.RawType() == fourcc {
+			found, ok = ch, true
+		}
+		return !ok
+	})
+
+	return found, ok
+}
+
+// hasChunk reports whether the image holds a chunk whose type bytes are
+// fourcc.
+func hasChunk(p *png.Parser, fourcc string) bool {
+	_, ok := firstChunk(p, fourcc)
+	return ok
+}
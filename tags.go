@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitlab.com/thedahv/pnguin/internal/ansi"
+	"gitlab.com/thedahv/pnguin/png"
+)
+
+// tagOptions controls how much detail printTags shows for each chunk
+type tagOptions struct {
+	verbose       bool
+	base64        bool
+	nullDelimited bool
+	color         bool
+	// lineLimit caps the number of characters shown of any chunk's data, or is
+	// 0 for no limit
+	lineLimit int
+}
+
+// base64LineLength is the maximum encoded line length allowed by MIME
+const base64LineLength = 76
+
+// histogramWidth is the length of the longest bar in a hIST bar chart
+const histogramWidth = 40
+
+// printTags lists the non-data chunks of a parsed image along with the
+// contents of its text chunks. In verbose mode every chunk is listed with its
+// position and size.
+func printTags(w io.Writer, p *png.Parser, opts tagOptions) {
+	if opts.nullDelimited {
+		printTextRecords(w, p)
+		return
+	}
+
+	fmt.Fprintf(w, "%s tags:\n", p.Path)
+	p.WalkChunks(func(ch png.Chunk) bool {
+		var style string
+		if opts.color {
+			style = chunkStyle(ch)
+		}
+
+		if opts.verbose {
+			fmt.Fprintf(w, "  [offset:0x%04x] %s (%s)\n",
+				ch.Offset, ansi.Wrap(style, ch.RawType()), chunkSize(ch))
+		} else if ch.IsUnknown() {
+			fmt.Fprintf(w, "  %s\n",
+				ansi.Wrap(style, fmt.Sprintf("%s (%s)", ch.Type, ch.RawType())))
+		} else if !isImageChunk(ch) {
+			fmt.Fprintf(w, "  %s\n", ansi.Wrap(style, ch.Type.String()))
+		}
+		if ch.Type == png.ChunkTypeTxtUTF8 || ch.Type == png.ChunkTypeTxtISO8859 {
+			if err := validateText(ch); err != nil {
+				fmt.Fprintf(w, "   invalid: %v\n", err)
+			}
+			if opts.verbose {
+				if i := bytes.IndexByte(ch.Data, 0); i >= 0 {
+					fmt.Fprintf(w, "   keyword: %q\n", ch.Data[:i])
+				}
+			}
+			fmt.Fprintf(w, "   %s\n", truncate(string(ch.Data), opts.lineLimit))
+		} else if ch.Type == png.ChunkTypePxSize {
+			fmt.Fprintf(w, "   %s\n", describePhys(ch.Data))
+		} else if ch.Type == png.ChunkTypeGamma {
+			fmt.Fprintf(w, "   %s\n", describeGamma(ch.Data))
+		} else if ch.Type == png.ChunkTypeRGB {
+			fmt.Fprintf(w, "   %s\n", describeSRGB(ch.Data))
+		} else if ch.Type == png.ChunkTypeTimeChanged {
+			fmt.Fprintf(w, "   %s\n", describeTime(ch.Data))
+		} else if ch.Type == png.ChunkTypeSugPalette {
+			fmt.Fprintf(w, "   %s\n", describeSPLT(ch.Data))
+		} else if ch.Type == png.ChunkTypeHistogram && opts.verbose {
+			printHistogram(w, ch.Data)
+		} else if ch.Type == png.ChunkTypeExif {
+			printExif(w, ch.Data, opts.lineLimit)
+		} else if opts.base64 && !isImageChunk(ch) {
+			printBase64(w, ch.Data, opts.lineLimit)
+		}
+		return true
+	})
+}
+
+// validateText checks that a tEXt or iTXt chunk can be decoded, which includes
+// checking its keyword.
+func validateText(ch png.Chunk) error {
+	var err error
+	if ch.Type == png.ChunkTypeTxtUTF8 {
+		_, err = png.ParseITXtChunk(ch.Data)
+	} else {
+		_, err = png.ParseTextChunk(ch.Data)
+	}
+
+	return err
+}
+
+// chunkStyle picks the terminal style for a chunk's type: bold for critical
+// chunks, green for text, yellow for EXIF, and red for unknown chunks.
+func chunkStyle(ch png.Chunk) string {
+	switch {
+	case ch.IsCritical():
+		return ansi.Bold
+	case ch.IsText():
+		return ansi.Green
+	case ch.Type == png.ChunkTypeExif:
+		return ansi.Yellow
+	case ch.IsUnknown():
+		return ansi.Red
+	default:
+		return ""
+	}
+}
+
+// printTextRecords writes the path of the image followed by a keyword=value
+// record for each text chunk, each terminated by a null byte in the manner of
+// `find -print0`.
+func printTextRecords(w io.Writer, p *png.Parser) {
+	fmt.Fprintf(w, "%s\x00", p.Path)
+	err := p.ForEachText(func(keyword, value string) bool {
+		fmt.Fprintf(w, "%s=%s\x00", keyword, value)
+		return true
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read text from %s: %v\n", p.Path, err)
+	}
+}
+
+// describePhys summarizes the resolution held in pHYs chunk data, e.g.
+// "72 DPI (2835x2835 pixels/metre)".
+func describePhys(data []byte) string {
+	phys, err := png.ParsePhysChunk(data)
+	if err != nil {
+		return fmt.Sprintf("invalid pHYs chunk: %v", err)
+	}
+
+	x, y, ok := phys.DPI()
+	if !ok {
+		return fmt.Sprintf("unknown units (%dx%d pixels/unit)",
+			phys.PixelsPerUnitX, phys.PixelsPerUnitY)
+	}
+
+	dpi := fmt.Sprintf("%.0fx%.0f DPI", x, y)
+	if fmt.Sprintf("%.0f", x) == fmt.Sprintf("%.0f", y) {
+		dpi = fmt.Sprintf("%.0f DPI", x)
+	}
+
+	return fmt.Sprintf("%s (%dx%d pixels/metre)", dpi,
+		phys.PixelsPerUnitX, phys.PixelsPerUnitY)
+}
+
+// describeGamma gives the gamma held in gAMA chunk data along with the setup
+// it corresponds to, e.g. "γ = 0.45455 (sRGB-compatible)".
+func describeGamma(data []byte) string {
+	gamma, err := png.ParseGammaChunk(data)
+	if err != nil {
+		return fmt.Sprintf("invalid gAMA chunk: %v", err)
+	}
+
+	near := func(v float64) bool {
+		return math.Abs(gamma-v) < 0.0001
+	}
+
+	var note string
+	switch {
+	case gamma == 0:
+		note = "invalid"
+	case near(0.45455):
+		note = "sRGB-compatible"
+	case near(1):
+		note = "linear"
+	case near(0.55556):
+		note = "classic Mac display gamma 1.8"
+	default:
+		note = fmt.Sprintf("display gamma %.2f", 1/gamma)
+	}
+
+	return fmt.Sprintf("γ = %.5f (%s)", gamma, note)
+}
+
+// describeSRGB gives the rendering intent held in sRGB chunk data, e.g.
+// "sRGB (Saturation)".
+func describeSRGB(data []byte) string {
+	intent, err := png.ParseSRGBChunk(data)
+	if err != nil {
+		return fmt.Sprintf("invalid sRGB chunk: %v", err)
+	}
+
+	return fmt.Sprintf("sRGB (%s)", intent)
+}
+
+// describeTime gives the timestamp held in tIME chunk data in RFC 3339 form,
+// e.g. "Last Changed: 2024-03-15T10:23:45Z". Some tools write January 1 of
+// year 1 to mean there is no timestamp, which is called out.
+func describeTime(data []byte) string {
+	t, err := png.ParseTimeChunk(data)
+	if err != nil {
+		return fmt.Sprintf("invalid tIME chunk: %v", err)
+	}
+
+	desc := fmt.Sprintf("Last Changed: %s", t.Format(time.RFC3339))
+	if y, m, d := t.Date(); y == 1 && m == time.January && d == 1 {
+		desc += " (epoch, likely no timestamp)"
+	}
+
+	return desc
+}
+
+// describeSPLT summarizes a suggested palette held in sPLT chunk data, e.g.
+// "'web216' 8-bit 216 entries". The entries themselves are left out.
+func describeSPLT(data []byte) string {
+	splt, err := png.ParseSPLTChunk(data)
+	if err != nil {
+		return fmt.Sprintf("invalid sPLT chunk: %v", err)
+	}
+
+	return fmt.Sprintf("'%s' %d-bit %d entries", splt.Name, splt.SampleDepth,
+		len(splt.Entries))
+}
+
+// printHistogram draws the frequencies held in hIST chunk data as a bar chart
+// with one line per palette entry, scaled so the most frequent entry fills
+// histogramWidth columns.
+func printHistogram(w io.Writer, data []byte) {
+	freqs, err := png.ParseHistogramChunk(data)
+	if err != nil {
+		fmt.Fprintf(w, "   invalid hIST chunk: %v\n", err)
+		return
+	}
+
+	var max uint16
+	for _, f := range freqs {
+		if f > max {
+			max = f
+		}
+	}
+
+	for i, f := range freqs {
+		var bar int
+		if max > 0 {
+			bar = int(f) * histogramWidth / int(max)
+		}
+		line := fmt.Sprintf("   %3d %5d %s", i, f, strings.Repeat("#", bar))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
+
+// printExif writes the header and basic fields of eXIf chunk data, followed by
+// the reason parsing stopped if the data is malformed. Entries that are not
+// decoded are cut off after limit characters if limit is positive.
+func printExif(w io.Writer, data []byte, limit int) {
+	exif, err := png.ParseExifChunk(data)
+
+	switch exif.ByteOrder {
+	case "II":
+		fmt.Fprintf(w, "   II (little-endian), TIFF magic %d, %d bytes\n",
+			exif.Magic, exif.Size)
+	case "MM":
+		fmt.Fprintf(w, "   MM (big-endian), TIFF magic %d, %d bytes\n",
+			exif.Magic, exif.Size)
+	default:
+		fmt.Fprintf(w, "   %d bytes\n", exif.Size)
+	}
+
+	if exif.ImageWidth != 0 || exif.ImageLength != 0 {
+		fmt.Fprintf(w, "   ImageSize: %dx%d\n", exif.ImageWidth, exif.ImageLength)
+	}
+	for _, field := range []struct{ name, value string }{
+		{"Make", exif.Make},
+		{"Model", exif.Model},
+		{"DateTime", exif.DateTime},
+		{"ImageDescription", exif.ImageDescription},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(w, "   %s: %s\n", field.name, field.value)
+		}
+	}
+	if exif.ExifIFDPointer != 0 {
+		fmt.Fprintf(w, "   ExifIFDPointer: %d\n", exif.ExifIFDPointer)
+	}
+	for _, tag := range exif.Other {
+		fmt.Fprintf(w, "   %s\n", truncate(tag.String(), limit))
+	}
+
+	if err != nil {
+		fmt.Fprintf(w, "   incomplete EXIF data: %v\n", err)
+	}
+}
+
+// isImageChunk reports whether the chunk is part of the image structure
+// rather than a tag describing it.
+func isImageChunk(ch png.Chunk) bool {
+	return ch.Type == png.ChunkTypeData || ch.Type == png.ChunkTypeHeader ||
+		ch.Type == png.ChunkTypeEnd
+}
+
+// printBase64 writes binary chunk data base64 encoded, wrapped to the MIME
+// line length. If limit is positive, only as much data as encodes to at most
+// limit characters is written, followed by a note on its own line of how many
+// bytes were left out, so that the encoded lines stay valid base64.
+func printBase64(w io.Writer, data []byte, limit int) {
+	shown := data
+	if max := limit / 4 * 3; limit > 0 && len(data) > max {
+		shown = data[:max]
+	}
+
+	enc := base64.StdEncoding.EncodeToString(shown)
+	for len(enc) > 0 {
+		n := base64LineLength
+		if n > len(enc) {
+			n = len(enc)
+		}
+
+		fmt.Fprintf(w, "   %s\n", enc[:n])
+		enc = enc[n:]
+	}
+	if left := len(data) - len(shown); left > 0 {
+		fmt.Fprintf(w, "   ... [%d more bytes]\n", left)
+	}
+}
+
+// truncate shortens s to at most limit characters and notes how many were
+// cut. Bytes that are not valid UTF-8 count as a character each. A limit of 0
+// or less leaves s as is.
+func truncate(s string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+
+	var n, count int
+	for i := range s {
+		if count == limit {
+			n = i
+			break
+		}
+		count++
+	}
+
+	return fmt.Sprintf("%s... [%d more characters]", s[:n],
+		utf8.RuneCountInString(s[n:]))
+}
+
+// chunkSize describes the data length of a chunk, calling out any difference
+// between the length declared in the file and the data actually held.
+func chunkSize(ch png.Chunk) string {
+	declared := int64(binary.BigEndian.Uint32(ch.Length[:]))
+	if actual := int64(len(ch.Data)); actual != declared {
+		return fmt.Sprintf("%s declared, %s read",
+			formatSize(declared), formatSize(actual))
+	}
+
+	return formatSize(declared)
+}
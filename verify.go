@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gitlab.com/thedahv/pnguin/png"
+)
+
+// runVerify implements the verify command, checking the signature, chunk CRCs,
+// chunk order, and header of each image and printing every problem found.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pnguin verify imgpath ...")
+		fmt.Fprintln(os.Stderr,
+			"Exits with status 0 only if every image is intact.")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		errs := png.Verify(f)
+		f.Close()
+		if len(errs) == 0 {
+			fmt.Printf("%s: OK\n", path)
+			continue
+		}
+
+		status = 1
+		for _, err := range errs {
+			fmt.Printf("%s: %v\n", path, err)
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often the watched directory is scanned for changes
+const watchInterval = time.Second
+
+// watch polls dir for new or modified PNG files and processes each one until
+// interrupted. Files present when watching starts are left alone, as are the
+// cleaned copies written alongside the originals.
+func watch(dir string, opts *options) error {
+	seen, err := scanPNGs(dir)
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	fmt.Fprintf(os.Stderr, "watching %s\n", dir)
+	for {
+		select {
+		case <-interrupt:
+			fmt.Fprintf(os.Stderr, "stopped watching %s\n", dir)
+			return nil
+		case <-ticker.C:
+			// The directory may be briefly unreadable, so only the first scan
+			// is fatal
+			found, err := scanPNGs(dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "unable to scan %s: %v\n", dir, err)
+				continue
+			}
+
+			for path, mod := range found {
+				if prev, ok := seen[path]; ok && prev.Equal(mod) {
+					continue
+				}
+
+				p, err := parseFile(path)
+				if err == nil {
+					_, err = process(p, 0, opts)
+				}
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "cleaned %s\n", path)
+			}
+			seen = found
+		}
+	}
+}
+
+// scanPNGs finds the PNG files under dir along with their modification times.
+// Only a failure to read dir itself is returned. Files that disappear during
+// the scan, as when an editor saves through a temporary file, are skipped, and
+// other problems with single entries are reported on stderr.
+func scanPNGs(dir string) (map[string]time.Time, error) {
+	found := make(map[string]time.Time)
+	skip := func(path string, err error) error {
+		if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "unable to scan %s: %v\n", path, err)
+		}
+		return nil
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return skip(path, err)
+		}
+
+		name := strings.ToLower(d.Name())
+		if d.IsDir() || !strings.HasSuffix(name, ".png") ||
+			strings.HasSuffix(name, "-cleaned.png") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return skip(path, err)
+		}
+		found[path] = info.ModTime()
+		return nil
+	})
+
+	return found, err
+}